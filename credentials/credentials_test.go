@@ -0,0 +1,119 @@
+package credentials
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_PasswordHashes(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Fatalf("unexpected hash %s", hash)
+	}
+	if rehash, err := VerifyPassword("secret", hash); err != nil || rehash {
+		t.Fatalf("unexpected %v %v", rehash, err)
+	}
+	if _, err := VerifyPassword("wrong", hash); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected ErrMismatch, got %v", err)
+	}
+
+	weak, _ := HashPasswordArgon2("secret", Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	if rehash, err := VerifyPassword("secret", weak); err != nil || !rehash {
+		t.Fatalf("expected rehash for weak params, got %v %v", rehash, err)
+	}
+
+	bc, err := HashPasswordBcrypt("secret", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rehash, err := VerifyPassword("secret", bc); err != nil || !rehash {
+		t.Fatalf("expected rehash for low cost, got %v %v", rehash, err)
+	}
+	if _, err := VerifyPassword("wrong", bc); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected ErrMismatch, got %v", err)
+	}
+}
+
+func Test_VerifyAndUpgradeMD5(t *testing.T) {
+	// utils.Jiami("secret")
+	legacy := "5ebe2294ecd0e0f08eab7690d2a6ee69"
+	var saved string
+	if err := VerifyAndUpgrade("secret", legacy, func(h string) error { saved = h; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if rehash, err := VerifyPassword("secret", saved); err != nil || rehash {
+		t.Fatalf("upgraded hash invalid: %v %v", rehash, err)
+	}
+	if err := VerifyAndUpgrade("wrong", legacy, nil); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected ErrMismatch, got %v", err)
+	}
+	if _, err := VerifyPassword("secret", "plain"); !errors.Is(err, ErrHashFormat) {
+		t.Fatalf("expected ErrHashFormat, got %v", err)
+	}
+}
+
+func Test_VerifyArgon2Params(t *testing.T) {
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(hash, "$")
+	// 参数为0或过大时不计算hash
+	for _, params := range []string{"m=65536,t=0,p=2", "m=65536,t=3,p=0", "m=0,t=3,p=2", "m=4294967295,t=3,p=2", "m=65536,t=100000,p=2", "m=65536,t=3,p=255"} {
+		parts[3] = params
+		if _, err := VerifyPassword("secret", strings.Join(parts, "$")); !errors.Is(err, ErrHashFormat) {
+			t.Fatalf("%s: expected ErrHashFormat, got %v", params, err)
+		}
+	}
+
+	// 生成时使用相同的范围
+	for _, p := range []Argon2Params{
+		{Memory: 1024, Iterations: 0, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		{Memory: 1024, Iterations: 1, Parallelism: 0, SaltLength: 16, KeyLength: 32},
+		{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 0},
+		{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 0, KeyLength: 32},
+		{Memory: 1 << 21, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32},
+		{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 256},
+	} {
+		if _, err := HashPasswordArgon2("secret", p); !errors.Is(err, ErrArgon2Params) {
+			t.Fatalf("%+v: expected ErrArgon2Params, got %v", p, err)
+		}
+	}
+	hash, err = HashPasswordArgon2("secret", Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 8, KeyLength: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyPassword("secret", hash); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_RandomGenerators(t *testing.T) {
+	s, err := RandomString(64, AlphabetHex)
+	if err != nil || len(s) != 64 || strings.Trim(s, AlphabetHex) != "" {
+		t.Fatalf("unexpected %q %v", s, err)
+	}
+	for _, alphabet := range []string{"a", "aab"} {
+		if _, err := RandomString(8, alphabet); !errors.Is(err, ErrAlphabet) {
+			t.Fatalf("%s: expected ErrAlphabet, got %v", alphabet, err)
+		}
+	}
+	if s, err := RandomString(-1, ""); err != nil || s != "" {
+		t.Fatalf("unexpected %q %v", s, err)
+	}
+	if a, _ := Token(32); len(a) != 43 {
+		t.Fatalf("unexpected token %q", a)
+	}
+
+	key, hash, err := NewAPIKey("lx")
+	if err != nil || !strings.HasPrefix(key, "lx_") {
+		t.Fatalf("unexpected key %q %v", key, err)
+	}
+	if !VerifyAPIKey(key, hash) || VerifyAPIKey(key+"x", hash) {
+		t.Fatal("api key verification failed")
+	}
+}
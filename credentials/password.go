@@ -0,0 +1,193 @@
+package credentials
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/lflxp/tools/logging"
+)
+
+var logger = logging.For("credentials")
+
+var (
+	ErrMismatch     = errors.New("credentials: password mismatch")
+	ErrHashFormat   = errors.New("credentials: unknown hash format")
+	ErrEmptySecret  = errors.New("credentials: empty password")
+	ErrArgon2Params = errors.New("credentials: invalid argon2 parameters")
+)
+
+// Argon2Params argon2id参数, 编码在hash中, 修改默认值不影响已有hash的校验
+type Argon2Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params 参考 RFC 9106 和 OWASP 推荐值
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// DefaultBcryptCost bcrypt默认cost, 低于该值的hash在登录时升级
+var DefaultBcryptCost = 12
+
+// HashPassword 使用默认参数的argon2id, 输出PHC格式:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func HashPassword(password string) (string, error) {
+	return HashPasswordArgon2(password, DefaultArgon2Params)
+}
+
+// HashPasswordArgon2 使用指定参数的argon2id, 参数超出校验时允许的范围返回ErrArgon2Params
+func HashPasswordArgon2(password string, p Argon2Params) (string, error) {
+	if password == "" {
+		return "", ErrEmptySecret
+	}
+	if !validArgon2(p) || p.SaltLength < minArgon2SaltLength {
+		return "", ErrArgon2Params
+	}
+	salt, err := Salt(int(p.SaltLength))
+	if err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// HashPasswordBcrypt bcrypt hash, cost为0时使用DefaultBcryptCost, 密码超过72字节会返回错误
+func HashPasswordBcrypt(password string, cost int) (string, error) {
+	if password == "" {
+		return "", ErrEmptySecret
+	}
+	if cost == 0 {
+		cost = DefaultBcryptCost
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	return string(hash), err
+}
+
+// VerifyPassword 常量时间校验argon2id、bcrypt以及旧的MD5(utils.Jiami/utils.MD5) hash
+// needsRehash为true表示校验通过但应当用HashPassword重新生成
+func VerifyPassword(password, encoded string) (needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return verifyArgon2(password, encoded)
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		if err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, ErrMismatch
+			}
+			return false, err
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err == nil && cost < DefaultBcryptCost, nil
+	case isLegacyMD5(encoded):
+		return true, verifyMD5(password, encoded)
+	default:
+		return false, ErrHashFormat
+	}
+}
+
+// VerifyAndUpgrade 登录时校验密码, 旧格式或参数过低的hash会重新生成并通过save保存
+// 升级失败只记录日志, 不影响本次登录
+func VerifyAndUpgrade(password, encoded string, save func(newHash string) error) error {
+	needsRehash, err := VerifyPassword(password, encoded)
+	if err != nil {
+		return err
+	}
+	if !needsRehash || save == nil {
+		return nil
+	}
+	hash, err := HashPassword(password)
+	if err == nil {
+		err = save(hash)
+	}
+	if err != nil {
+		logger.Warn("upgrade password hash failed", "error", err)
+	}
+	return nil
+}
+
+// 允许的argon2参数上限, 避免被篡改的hash耗尽内存或CPU; 生成hash时使用相同范围, 保证能通过校验
+const (
+	maxArgon2Memory      = 1 << 20 // 1GiB
+	maxArgon2Iterations  = 16
+	maxArgon2Parallelism = 16
+	maxArgon2KeyLength   = 128
+	minArgon2SaltLength  = 8
+)
+
+func validArgon2(p Argon2Params) bool {
+	return p.Memory > 0 && p.Memory <= maxArgon2Memory && p.Iterations > 0 && p.Iterations <= maxArgon2Iterations &&
+		p.Parallelism > 0 && p.Parallelism <= maxArgon2Parallelism && p.KeyLength > 0 && p.KeyLength <= maxArgon2KeyLength
+}
+
+func verifyArgon2(password, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	// "", argon2id, v=19, m=..,t=..,p=.., salt, hash
+	if len(parts) != 6 {
+		return false, ErrHashFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, ErrHashFormat
+	}
+	var p Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return false, ErrHashFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, ErrHashFormat
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(want) > maxArgon2KeyLength {
+		return false, ErrHashFormat
+	}
+	p.KeyLength = uint32(len(want))
+	if !validArgon2(p) {
+		return false, ErrHashFormat
+	}
+
+	got := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(want)))
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return false, ErrMismatch
+	}
+	d := DefaultArgon2Params
+	return p.Memory < d.Memory || p.Iterations < d.Iterations || p.Parallelism < d.Parallelism || uint32(len(want)) < d.KeyLength, nil
+}
+
+func isLegacyMD5(encoded string) bool {
+	if len(encoded) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
+
+// verifyMD5 兼容utils.Jiami(原文)和utils.MD5(去掉首尾空白)
+func verifyMD5(password, encoded string) error {
+	want := []byte(strings.ToLower(encoded))
+	raw := md5.Sum([]byte(password))
+	trimmed := md5.Sum([]byte(strings.TrimSpace(password)))
+	ok := subtle.ConstantTimeCompare([]byte(hex.EncodeToString(raw[:])), want) |
+		subtle.ConstantTimeCompare([]byte(hex.EncodeToString(trimmed[:])), want)
+	if ok != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package credentials
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// 常用字符集
+const (
+	AlphabetNumeric      = "0123456789"
+	AlphabetHex          = "0123456789abcdef"
+	AlphabetAlphanumeric = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// AlphabetBase58 去掉了容易混淆的0、O、I、l
+	AlphabetBase58 = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+// APIKeyLength APIKey随机部分的长度
+const APIKeyLength = 32
+
+var ErrAlphabet = errors.New("credentials: alphabet must have 2 to 256 unique characters")
+
+// RandomString 使用crypto/rand从alphabet中均匀选择n个字符, alphabet为空时使用AlphabetAlphanumeric
+func RandomString(n int, alphabet string) (string, error) {
+	if alphabet == "" {
+		alphabet = AlphabetAlphanumeric
+	}
+	chars := []rune(alphabet)
+	if len(chars) < 2 || len(chars) > 256 {
+		return "", ErrAlphabet
+	}
+	// 重复的字符会使选择不均匀
+	seen := make(map[rune]bool, len(chars))
+	for _, c := range chars {
+		if seen[c] {
+			return "", ErrAlphabet
+		}
+		seen[c] = true
+	}
+
+	if n <= 0 {
+		return "", nil
+	}
+
+	max := big.NewInt(int64(len(chars)))
+	var b strings.Builder
+	b.Grow(n)
+	for i := 0; i < n; i++ {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b.WriteRune(chars[idx.Int64()])
+	}
+	return b.String(), nil
+}
+
+// Salt 生成n字节随机salt
+func Salt(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Token 生成n字节随机数的base64url字符串, 用于session、重置密码链接等
+func Token(n int) (string, error) {
+	b, err := Salt(n)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewAPIKey 生成 <prefix>_<随机字符串> 形式的API key, 数据库中只保存hash
+func NewAPIKey(prefix string) (key, hash string, err error) {
+	random, err := RandomString(APIKeyLength, AlphabetBase58)
+	if err != nil {
+		return "", "", err
+	}
+	if prefix != "" {
+		key = prefix + "_" + random
+	} else {
+		key = random
+	}
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey API key为高熵随机数, 使用sha256即可, 不需要慢hash
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAPIKey 常量时间比较API key和保存的hash
+func VerifyAPIKey(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hash)) == 1
+}
@@ -0,0 +1,274 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/logging"
+)
+
+var logger = logging.For("httpclient")
+
+// Severity 错误等级, 决定SendError的日志级别
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// ShowType 前端展示方式(与ant design pro errorShowType一致)
+const (
+	ShowTypeSilent       = "0"
+	ShowTypeWarn         = "1"
+	ShowTypeError        = "2"
+	ShowTypeNotification = "4"
+	ShowTypeRedirect     = "9"
+)
+
+// 支持的语言, Messages按语言存放, 找不到时退回DefaultLanguage
+const (
+	LanguageZh = "zh"
+	LanguageEn = "en"
+)
+
+var DefaultLanguage = LanguageZh
+
+// ErrorCode 错误码定义
+type ErrorCode struct {
+	Code     string
+	Status   int
+	Severity Severity
+	ShowType string
+	Messages map[string]string
+}
+
+// Message 按语言顺序返回第一个存在的文案, 都不存在时依次使用DefaultLanguage、LanguageEn
+func (e *ErrorCode) Message(langs ...string) string {
+	// 复制一份, append不能写入调用方的底层数组
+	candidates := make([]string, 0, len(langs)+2)
+	candidates = append(candidates, langs...)
+	for _, lang := range append(candidates, DefaultLanguage, LanguageEn) {
+		if msg, ok := e.Messages[lang]; ok {
+			return msg
+		}
+	}
+	// 只注册了其他语言时取排序后的第一个, 保证结果固定
+	if len(e.Messages) > 0 {
+		langs := make([]string, 0, len(e.Messages))
+		for lang := range e.Messages {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		return e.Messages[langs[0]]
+	}
+	return e.Code
+}
+
+var (
+	errorCodesLock sync.RWMutex
+	errorCodes     = map[string]*ErrorCode{}
+)
+
+func init() {
+	for _, ec := range []ErrorCode{
+		{Failed, http.StatusBadRequest, SeverityWarning, ShowTypeError, map[string]string{LanguageZh: "操作失败!", LanguageEn: "Operation failed!"}},
+		{FailedRemoteServiceError, http.StatusBadGateway, SeverityError, ShowTypeError, map[string]string{LanguageZh: "调用第三方接口异常!", LanguageEn: "Remote service error!"}},
+		{FailedParamsError, http.StatusBadRequest, SeverityWarning, ShowTypeWarn, map[string]string{LanguageZh: "参数错误!", LanguageEn: "Invalid parameters!"}},
+		{FailedUnknown, http.StatusInternalServerError, SeverityError, ShowTypeError, map[string]string{LanguageZh: "操作失败，未知错误!", LanguageEn: "Operation failed, unknown error!"}},
+		{FailedDecodeError, http.StatusBadRequest, SeverityWarning, ShowTypeError, map[string]string{LanguageZh: "解码失败", LanguageEn: "Decode failed"}},
+		{AthorizationError, http.StatusUnauthorized, SeverityInfo, ShowTypeRedirect, map[string]string{LanguageZh: "未授权, 请求头缺少Authorization", LanguageEn: "Header Not Contains Authorization"}},
+		{JsonError, http.StatusBadRequest, SeverityWarning, ShowTypeError, map[string]string{LanguageZh: "json序列化错误", LanguageEn: "JSON serialization error"}},
+		{ResourceNotFound, http.StatusNotFound, SeverityInfo, ShowTypeWarn, map[string]string{LanguageZh: "资源不存在", LanguageEn: "Resource not found"}},
+		{LicenseError, http.StatusForbidden, SeverityWarning, ShowTypeNotification, map[string]string{LanguageZh: "License无效或已过期", LanguageEn: "License is invalid or expired"}},
+		{BiddenError, http.StatusForbidden, SeverityWarning, ShowTypeError, map[string]string{LanguageZh: "没有权限", LanguageEn: "Forbidden"}},
+		{RoleChangeNeedReLogin, http.StatusUnauthorized, SeverityInfo, ShowTypeRedirect, map[string]string{LanguageZh: "角色已变更, 请重新登录", LanguageEn: "Role changed, please login again"}},
+		{ResourceConflict, http.StatusConflict, SeverityInfo, ShowTypeWarn, map[string]string{LanguageZh: "资源冲突, 已存在或已被修改", LanguageEn: "Resource already exists or was modified"}},
+		{SystemError, http.StatusInternalServerError, SeverityError, ShowTypeError, map[string]string{LanguageZh: "系统异常!", LanguageEn: "System error!"}},
+	} {
+		RegisterErrorCode(ec)
+	}
+}
+
+// RegisterErrorCode 注册或覆盖错误码, Status为0时默认400
+func RegisterErrorCode(ec ErrorCode) {
+	if ec.Status == 0 {
+		ec.Status = http.StatusBadRequest
+	}
+	if ec.Severity == "" {
+		ec.Severity = SeverityError
+	}
+	if ec.ShowType == "" {
+		ec.ShowType = ShowTypeError
+	}
+	messages := make(map[string]string, len(ec.Messages))
+	for lang, msg := range ec.Messages {
+		messages[strings.ToLower(lang)] = msg
+	}
+	ec.Messages = messages
+
+	errorCodesLock.Lock()
+	defer errorCodesLock.Unlock()
+	errorCodes[ec.Code] = &ec
+}
+
+// LookupErrorCode 查询错误码定义, 返回副本, 修改需要使用RegisterErrorCode
+func LookupErrorCode(code string) (*ErrorCode, bool) {
+	errorCodesLock.RLock()
+	defer errorCodesLock.RUnlock()
+	ec, ok := errorCodes[code]
+	if !ok {
+		return nil, false
+	}
+	copied := *ec
+	copied.Messages = make(map[string]string, len(ec.Messages))
+	for lang, msg := range ec.Messages {
+		copied.Messages[lang] = msg
+	}
+	return &copied, true
+}
+
+// AcceptLanguages 按q值从高到低返回Accept-Language中的语言, 只取主语言 zh-CN => zh
+func AcceptLanguages(c *gin.Context) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+
+	var items []weighted
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" || lang == "*" {
+			continue
+		}
+		if i := strings.IndexAny(lang, "-_"); i > 0 {
+			lang = lang[:i]
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q > 0 {
+			items = append(items, weighted{lang, q})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+
+	langs := make([]string, 0, len(items))
+	for _, item := range items {
+		langs = append(langs, item.lang)
+	}
+	return langs
+}
+
+// CodeError 携带错误码的error, 可以包装任意error
+type CodeError struct {
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *CodeError) Error() string {
+	switch {
+	case e.Message != "" && e.Err != nil:
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	case e.Message != "":
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	case e.Err != nil:
+		return fmt.Sprintf("%s: %v", e.Code, e.Err)
+	default:
+		return e.Code
+	}
+}
+
+func (e *CodeError) Unwrap() error {
+	return e.Err
+}
+
+// NewError 返回只有错误码的error, 文案由注册表按语言决定
+func NewError(code string) error {
+	return &CodeError{Code: code}
+}
+
+// Errorf 返回带错误码和自定义文案的error
+func Errorf(code, format string, args ...interface{}) error {
+	return &CodeError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// WithCode 给err附加错误码, err为nil时返回nil
+func WithCode(err error, code string) error {
+	if err == nil {
+		return nil
+	}
+	return &CodeError{Code: code, Err: err}
+}
+
+// ErrorCodeOf 返回err链上最近的错误码, 没有时返回FailedUnknown
+func ErrorCodeOf(err error) string {
+	var ce *CodeError
+	if errors.As(err, &ce) {
+		return ce.Code
+	}
+	return FailedUnknown
+}
+
+// SendError 根据err上的错误码选择http状态码、ShowType和当前语言的文案
+// 只返回Errorf指定的文案, 被包装的err可能包含数据库等内部信息, 只记录日志
+func SendError(c *gin.Context, err error) {
+	code := FailedUnknown
+	var detail string
+	var ce *CodeError
+	if errors.As(err, &ce) {
+		code = ce.Code
+		detail = ce.Message
+	}
+
+	status := http.StatusInternalServerError
+	showType := ShowTypeError
+	severity := SeverityError
+	msg := detail
+	if ec, ok := LookupErrorCode(code); ok {
+		status = ec.Status
+		showType = ec.ShowType
+		severity = ec.Severity
+		msg = ec.Message(AcceptLanguages(c)...)
+		if detail != "" {
+			msg = fmt.Sprintf("%s: %s", msg, detail)
+		}
+	}
+
+	attrs := []any{"code", code, "path", c.Request.URL.Path, "error", err}
+	switch severity {
+	case SeverityInfo:
+		logger.InfoContext(c.Request.Context(), "request failed", attrs...)
+	case SeverityWarning:
+		logger.WarnContext(c.Request.Context(), "request failed", attrs...)
+	default:
+		logger.ErrorContext(c.Request.Context(), "request failed", attrs...)
+	}
+
+	info := &Result{
+		Success:      false,
+		ErrorCode:    code,
+		ErrorMessage: msg,
+		Host:         c.Request.URL.Path,
+		ShowType:     showType,
+	}
+
+	c.Writer.Header().Del("Content-Encoding")
+	render(c, status, info)
+}
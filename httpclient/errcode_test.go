@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_ErrorCodeMessage(t *testing.T) {
+	ec := &ErrorCode{Code: "x", Messages: map[string]string{LanguageZh: "中文", LanguageEn: "english"}}
+	if msg := ec.Message("fr", LanguageEn); msg != "english" {
+		t.Fatalf("unexpected %s", msg)
+	}
+	if msg := ec.Message("fr"); msg != "中文" {
+		t.Fatalf("expected default language, got %s", msg)
+	}
+
+	// 没有默认语言时结果固定
+	ec = &ErrorCode{Code: "x", Messages: map[string]string{"ja": "ja", "de": "de", "fr": "fr"}}
+	for i := 0; i < 20; i++ {
+		if msg := ec.Message(); msg != "de" {
+			t.Fatalf("unexpected fallback %s", msg)
+		}
+	}
+	if msg := (&ErrorCode{Code: "x"}).Message(); msg != "x" {
+		t.Fatalf("unexpected %s", msg)
+	}
+
+	// 不写入调用方的底层数组
+	langs := make([]string, 1, 3)
+	langs[0] = "fr"
+	ec.Message(langs...)
+	if full := langs[:3]; full[1] != "" || full[2] != "" {
+		t.Fatalf("caller slice modified %v", full)
+	}
+}
+
+func Test_LookupErrorCodeCopy(t *testing.T) {
+	ec, ok := LookupErrorCode(SystemError)
+	if !ok {
+		t.Fatal("SystemError not registered")
+	}
+	ec.Status = http.StatusTeapot
+	ec.Messages[LanguageEn] = "changed"
+	if again, _ := LookupErrorCode(SystemError); again.Status != http.StatusInternalServerError || again.Messages[LanguageEn] != "System error!" {
+		t.Fatalf("registry modified through lookup %+v", again)
+	}
+}
+
+func Test_AcceptLanguages(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("Accept-Language", "fr;q=0.2, en-US;q=0.8, zh_CN, *;q=0.1, de;q=0")
+	if langs := strings.Join(AcceptLanguages(c), ","); langs != "zh,en,fr" {
+		t.Fatalf("unexpected languages %s", langs)
+	}
+}
+
+func Test_SendError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/db", func(c *gin.Context) {
+		SendError(c, WithCode(errors.New("pq: password authentication failed for user admin"), SystemError))
+	})
+	r.GET("/param", func(c *gin.Context) {
+		SendError(c, Errorf(FailedParamsError, "name is required"))
+	})
+	r.GET("/plain", func(c *gin.Context) {
+		SendError(c, errors.New("dial tcp 10.0.0.1:5432"))
+	})
+
+	cases := []struct {
+		path    string
+		status  int
+		code    string
+		message string
+	}{
+		{"/db", http.StatusInternalServerError, SystemError, "System error!"},
+		{"/param", http.StatusBadRequest, FailedParamsError, "Invalid parameters!: name is required"},
+		{"/plain", http.StatusInternalServerError, FailedUnknown, "Operation failed, unknown error!"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept-Language", "en")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var result Result
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		// 被包装的内部错误不返回给客户端
+		if w.Code != tc.status || result.ErrorCode != tc.code || result.ErrorMessage != tc.message {
+			t.Fatalf("%s: unexpected %d %+v", tc.path, w.Code, result)
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"sigs.k8s.io/yaml"
+
+	"github.com/lflxp/tools/sdk/apicache/pkg/api"
+)
+
+// 支持的响应格式
+const (
+	FormatJSON   = "json"
+	FormatYAML   = "yaml"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// ParameterFormat ?format=yaml 优先级高于 Accept header
+const ParameterFormat = "format"
+
+const (
+	MIMEYAML   = "application/x-yaml"
+	MIMECSV    = "text/csv"
+	MIMENDJSON = "application/x-ndjson"
+)
+
+// 流式输出时每写多少行刷新一次
+const streamFlushRows = 100
+
+// 参与 Accept 协商的 MIME, 第一个为 */* 时的默认值
+var negotiateMIMEs = []string{
+	gin.MIMEJSON,
+	MIMEYAML,
+	"application/yaml",
+	"text/yaml",
+	MIMECSV,
+	MIMENDJSON,
+	"application/ndjson",
+	"application/jsonl",
+}
+
+var mimeFormats = map[string]string{
+	gin.MIMEJSON:         FormatJSON,
+	MIMEYAML:             FormatYAML,
+	"application/yaml":   FormatYAML,
+	"text/yaml":          FormatYAML,
+	MIMECSV:              FormatCSV,
+	MIMENDJSON:           FormatNDJSON,
+	"application/ndjson": FormatNDJSON,
+	"application/jsonl":  FormatNDJSON,
+}
+
+// NegotiateFormat 根据 ?format= 或 Accept header 选择响应格式, 默认json
+func NegotiateFormat(c *gin.Context) string {
+	if format := strings.ToLower(strings.TrimSpace(c.Query(ParameterFormat))); format != "" {
+		switch format {
+		case FormatYAML, "yml":
+			return FormatYAML
+		case FormatCSV:
+			return FormatCSV
+		case FormatNDJSON, "jsonl":
+			return FormatNDJSON
+		default:
+			return FormatJSON
+		}
+	}
+
+	if format, ok := mimeFormats[negotiateAccept(c.GetHeader("Accept"))]; ok {
+		return format
+	}
+	return FormatJSON
+}
+
+// negotiateAccept 按q值选择negotiateMIMEs中的MIME, 支持 type/* 和 */*
+// gin的Context.NegotiateFormat在 application/jsonl 与 application/json 比较时会越界panic, 因此不使用
+func negotiateAccept(accept string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mime, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mime = strings.ToLower(strings.TrimSpace(mime))
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(k) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		for _, offer := range negotiateMIMEs {
+			if mime == offer || mime == "*/*" || (strings.HasSuffix(mime, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mime, "*"))) {
+				best, bestQ = offer, q
+				break
+			}
+		}
+	}
+	return best
+}
+
+// render 按协商结果输出Result
+// yaml: 成功时按kubectl风格只输出data, 列表输出为 kind: List
+// csv/ndjson: 列表逐行流式输出, 失败时退回json
+func render(c *gin.Context, code int, info *Result) {
+	switch NegotiateFormat(c) {
+	case FormatYAML:
+		if !info.Success {
+			writeYAML(c, code, info)
+			return
+		}
+		renderYAML(c, code, info.Data)
+	case FormatCSV:
+		if !info.Success {
+			c.JSONP(code, info)
+			return
+		}
+		renderCSV(c, code, info.Data)
+	case FormatNDJSON:
+		if !info.Success {
+			c.JSONP(code, info)
+			return
+		}
+		renderNDJSON(c, code, info.Data)
+	default:
+		c.JSONP(code, info)
+	}
+}
+
+func writeYAML(c *gin.Context, code int, obj interface{}) {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		c.JSONP(http.StatusInternalServerError, &Result{
+			Success:      false,
+			ErrorCode:    JsonError,
+			ErrorMessage: err.Error(),
+			Host:         c.Request.URL.Path,
+		})
+		return
+	}
+	c.Data(code, MIMEYAML, data)
+}
+
+func renderYAML(c *gin.Context, code int, data interface{}) {
+	rows, pagination, ok := listRows(data)
+	if !ok {
+		writeYAML(c, code, data)
+		return
+	}
+
+	c.Header("Content-Type", MIMEYAML)
+	c.Status(code)
+
+	w := c.Writer
+	io.WriteString(w, "apiVersion: v1\n")
+	count := 0
+	err := rows(func(row interface{}) error {
+		out, err := yaml.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			io.WriteString(w, "items:\n")
+		}
+		if _, err := w.Write(indentListItem(out)); err != nil {
+			return err
+		}
+		count++
+		if count%streamFlushRows == 0 {
+			w.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		streamAborted(c, FormatYAML, err)
+		return
+	}
+	if count == 0 {
+		io.WriteString(w, "items: []\n")
+	}
+	io.WriteString(w, "kind: List\n")
+
+	meta := map[string]interface{}{"resourceVersion": ""}
+	if pagination != nil {
+		meta["limit"] = pagination.Limit
+		meta["offset"] = pagination.Offset
+		meta["page"] = pagination.Page
+		meta["total"] = pagination.Total
+	}
+	out, _ := yaml.Marshal(map[string]interface{}{"metadata": meta})
+	w.Write(out)
+}
+
+// indentListItem 将单个对象的yaml转成列表项 "- a: 1\n  b: 2\n"
+func indentListItem(out []byte) []byte {
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	var buf bytes.Buffer
+	for i, line := range lines {
+		if i == 0 {
+			buf.WriteString("- ")
+		} else if line != "" {
+			buf.WriteString("  ")
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func renderNDJSON(c *gin.Context, code int, data interface{}) {
+	rows, _, ok := listRows(data)
+	if !ok {
+		rows = singleRow(data)
+	}
+
+	c.Header("Content-Type", MIMENDJSON)
+	c.Status(code)
+
+	w := c.Writer
+	enc := json.NewEncoder(w)
+	count := 0
+	err := rows(func(row interface{}) error {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+		count++
+		if count%streamFlushRows == 0 {
+			w.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		streamAborted(c, FormatNDJSON, err)
+	}
+}
+
+// renderCSV 将每一行拍平成 metadata.name 形式的列
+// 列名为所有行拍平后key的并集, 先遍历一遍收集列名再逐行输出, 缺少的列为空
+func renderCSV(c *gin.Context, code int, data interface{}) {
+	rows, _, ok := listRows(data)
+	if !ok {
+		rows = singleRow(data)
+	}
+
+	seen := map[string]bool{}
+	var columns []string
+	err := rows(func(row interface{}) error {
+		flat, err := flattenRow(row)
+		if err != nil {
+			return err
+		}
+		for k := range flat {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSONP(http.StatusInternalServerError, &Result{
+			Success:      false,
+			ErrorCode:    JsonError,
+			ErrorMessage: err.Error(),
+			Host:         c.Request.URL.Path,
+		})
+		return
+	}
+	sort.Strings(columns)
+
+	c.Header("Content-Type", MIMECSV+"; charset=utf-8")
+	c.Status(code)
+
+	cw := csv.NewWriter(c.Writer)
+	if len(columns) > 0 {
+		if err := cw.Write(columns); err != nil {
+			streamAborted(c, FormatCSV, err)
+			return
+		}
+	}
+	count := 0
+	err = rows(func(row interface{}) error {
+		flat, err := flattenRow(row)
+		if err != nil {
+			return err
+		}
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			record[i] = flat[col]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+		count++
+		if count%streamFlushRows == 0 {
+			cw.Flush()
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	cw.Flush()
+	if err == nil {
+		err = cw.Error()
+	}
+	if err != nil {
+		streamAborted(c, FormatCSV, err)
+	}
+}
+
+// 状态码已经写出, 只能记录错误并中断
+func streamAborted(c *gin.Context, format string, err error) {
+	logger.ErrorContext(c.Request.Context(), "stream response aborted", "format", format, "path", c.Request.URL.Path, "error", err)
+	c.Error(err)
+	c.Abort()
+}
+
+type rowIterator func(fn func(row interface{}) error) error
+
+// listRows 识别ListResult和切片, 返回逐行迭代器, 避免整体序列化
+func listRows(data interface{}) (rowIterator, *api.Pagination, bool) {
+	switch v := data.(type) {
+	case *api.ListResult:
+		if v == nil {
+			return nil, nil, false
+		}
+		return sliceRows(v.Data), &v.Pagination, true
+	case api.ListResult:
+		return sliceRows(v.Data), &v.Pagination, true
+	case []interface{}:
+		return sliceRows(v), nil, true
+	}
+
+	rv := reflect.ValueOf(data)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, nil, false
+	}
+	// []byte 不是列表
+	if rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, nil, false
+	}
+	return func(fn func(row interface{}) error) error {
+		for i := 0; i < rv.Len(); i++ {
+			if err := fn(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil, true
+}
+
+func sliceRows(items []interface{}) rowIterator {
+	return func(fn func(row interface{}) error) error {
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func singleRow(data interface{}) rowIterator {
+	return func(fn func(row interface{}) error) error {
+		if data == nil {
+			return nil
+		}
+		return fn(data)
+	}
+}
+
+// flattenRow 嵌套对象用 . 连接key, 数组以json字符串作为单元格
+func flattenRow(row interface{}) (map[string]string, error) {
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+
+	var obj interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+
+	flat := map[string]string{}
+	if m, ok := obj.(map[string]interface{}); ok {
+		flattenInto(flat, "", m)
+	} else {
+		flat["value"] = cellString(obj)
+	}
+	return flat, nil
+}
+
+func flattenInto(flat map[string]string, prefix string, m map[string]interface{}) {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if child, ok := v.(map[string]interface{}); ok && len(child) > 0 {
+			flattenInto(flat, key, child)
+			continue
+		}
+		flat[key] = cellString(v)
+	}
+}
+
+func cellString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return fmt.Sprintf("%t", val)
+	default:
+		out, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(out)
+	}
+}
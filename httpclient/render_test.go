@@ -0,0 +1,93 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/sdk/apicache/pkg/api"
+)
+
+func renderRequest(t *testing.T, target, accept string, data interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/items", func(c *gin.Context) { SendSuccessMessage(c, http.StatusOK, data) })
+	r.GET("/fail", func(c *gin.Context) { SendErrorMessage(c, http.StatusBadRequest, FailedParamsError, "bad") })
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func Test_NegotiateFormat(t *testing.T) {
+	cases := []struct {
+		target, accept, format string
+	}{
+		{"/items", "", FormatJSON},
+		{"/items", "*/*", FormatJSON},
+		{"/items", "text/yaml", FormatYAML},
+		{"/items", "application/jsonl", FormatNDJSON},
+		{"/items", "application/json;q=0.1, text/csv;q=0.9", FormatCSV},
+		{"/items", "text/*", FormatYAML},
+		{"/items", "application/xml", FormatJSON},
+		{"/items?format=yml", "text/csv", FormatYAML},
+		{"/items?format=unknown", "text/csv", FormatJSON},
+	}
+	for _, tc := range cases {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.accept != "" {
+			c.Request.Header.Set("Accept", tc.accept)
+		}
+		if got := NegotiateFormat(c); got != tc.format {
+			t.Errorf("%s %q: expected %s, got %s", tc.target, tc.accept, tc.format, got)
+		}
+	}
+}
+
+func Test_RenderFormats(t *testing.T) {
+	list := &api.ListResult{
+		Data: []interface{}{
+			map[string]interface{}{"name": "a", "meta": map[string]interface{}{"ns": "x"}},
+			map[string]interface{}{"name": "b", "extra": true},
+		},
+		Pagination: api.Pagination{Limit: 10, Total: 2},
+	}
+
+	w := renderRequest(t, "/items?format=yaml", "", list)
+	if body := w.Body.String(); !strings.Contains(body, "kind: List") || !strings.Contains(body, "- meta:\n    ns: x\n  name: a\n") || !strings.Contains(body, "total: 2") {
+		t.Fatalf("unexpected yaml %q", body)
+	}
+
+	w = renderRequest(t, "/items", "application/x-ndjson", list)
+	if body := w.Body.String(); body != "{\"meta\":{\"ns\":\"x\"},\"name\":\"a\"}\n{\"extra\":true,\"name\":\"b\"}\n" {
+		t.Fatalf("unexpected ndjson %q", body)
+	}
+
+	// 列名为所有行的并集
+	w = renderRequest(t, "/items?format=csv", "", list)
+	if body := w.Body.String(); body != "extra,meta.ns,name\n,x,a\ntrue,,b\n" {
+		t.Fatalf("unexpected csv %q", body)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, MIMECSV) {
+		t.Fatalf("unexpected content type %s", ct)
+	}
+
+	// 失败时csv退回json, yaml输出完整Result
+	w = renderRequest(t, "/fail?format=csv", "", nil)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"errorCode":"4002"`) {
+		t.Fatalf("unexpected error response %d %s", w.Code, w.Body.String())
+	}
+	w = renderRequest(t, "/fail", "application/yaml", nil)
+	if !strings.Contains(w.Body.String(), "errorCode: \"4002\"") {
+		t.Fatalf("unexpected yaml error %q", w.Body.String())
+	}
+}
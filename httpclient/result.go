@@ -19,6 +19,7 @@ const (
 	LicenseError             = "4008"
 	BiddenError              = "4009"
 	RoleChangeNeedReLogin    = "4010"
+	ResourceConflict         = "4011"
 )
 
 // system error
@@ -26,17 +27,6 @@ const (
 	SystemError = "9000"
 )
 
-var responseMap = map[string]string{
-	Failed:                   "操作失败!",
-	FailedParamsError:        "参数错误!",
-	FailedUnknown:            "操作失败，未知错误!",
-	FailedRemoteServiceError: "调用第三方接口异常!",
-	SystemError:              "系统异常!",
-	FailedDecodeError:        "解码失败",
-	AthorizationError:        "Header Not Contains Authorization",
-	JsonError:                "json序列化错误",
-}
-
 type Result struct {
 	Success      bool        `json:"success"`
 	Data         interface{} `json:"data"`
@@ -65,10 +55,11 @@ func SendMessage(c *gin.Context, code int, success bool, data interface{}, errco
 		TraceId:      traceid,
 		ShowType:     showtype,
 	}
-	c.JSONP(code, info)
+	render(c, code, info)
 }
 
 // SendSuccessMessage select success and have data
+// 支持 ?format=yaml|csv|ndjson 或 Accept header 协商输出格式
 func SendSuccessMessage(c *gin.Context, code int, data interface{}) {
 	info := &Result{
 		Success: true,
@@ -76,13 +67,18 @@ func SendSuccessMessage(c *gin.Context, code int, data interface{}) {
 		Host:    c.Request.URL.Path,
 	}
 
-	c.JSONP(code, info)
+	render(c, code, info)
 }
 
 // SendErrorMessage select fail
+// errorMsg为空时使用错误码注册表中当前语言的文案
 func SendErrorMessage(c *gin.Context, code int, errorCode string, errorMsg string) {
-	if errorMsg == "" {
-		errorMsg = responseMap[errorCode]
+	var showType string
+	if ec, ok := LookupErrorCode(errorCode); ok {
+		if errorMsg == "" {
+			errorMsg = ec.Message(AcceptLanguages(c)...)
+		}
+		showType = ec.ShowType
 	}
 
 	info := &Result{
@@ -91,8 +87,9 @@ func SendErrorMessage(c *gin.Context, code int, errorCode string, errorMsg strin
 		ErrorCode:    errorCode,
 		ErrorMessage: errorMsg,
 		Host:         c.Request.URL.Path,
+		ShowType:     showType,
 	}
 
 	c.Writer.Header().Del("Content-Encoding")
-	c.JSONP(code, info)
+	render(c, code, info)
 }
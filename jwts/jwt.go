@@ -0,0 +1,225 @@
+package jwts
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/lflxp/tools/rsa"
+)
+
+// token类型, 防止refresh token被当作access token使用
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+var (
+	ErrTokenType   = errors.New("jwts: wrong token type")
+	ErrUnknownKey  = errors.New("jwts: unknown key id")
+	ErrNoKeyRing   = errors.New("jwts: key ring not configured")
+	ErrRoleChanged = errors.New("jwts: role changed, need re-login")
+)
+
+// Claims token中的声明, Extra为自定义字段
+type Claims struct {
+	jwt.RegisteredClaims
+	Type        string                 `json:"typ,omitempty"`
+	Roles       []string               `json:"roles,omitempty"`
+	RoleVersion string                 `json:"rv,omitempty"`
+	Extra       map[string]interface{} `json:"ext,omitempty"`
+}
+
+// Identity 签发token时的身份信息
+type Identity struct {
+	Subject     string
+	Roles       []string
+	RoleVersion string
+	Extra       map[string]interface{}
+}
+
+// TokenPair 登录和刷新返回的token
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	TokenType        string    `json:"token_type"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at,omitempty"`
+}
+
+// Issuer 使用rsa.KeyRing的active密钥签发token, 按kid查找密钥验证token
+// Algorithm为空时按active密钥类型选择: RSA=PS256, Ed25519=EdDSA
+type Issuer struct {
+	KeyRing    *rsa.KeyRing
+	Algorithm  rsa.SignAlgorithm
+	Issuer     string
+	Audience   []string
+	Timeout    time.Duration
+	MaxRefresh time.Duration
+}
+
+// NewIssuer 默认access token 1小时, refresh token 7天
+func NewIssuer(ring *rsa.KeyRing, issuer string, audience ...string) *Issuer {
+	return &Issuer{
+		KeyRing:    ring,
+		Issuer:     issuer,
+		Audience:   audience,
+		Timeout:    time.Hour,
+		MaxRefresh: 7 * 24 * time.Hour,
+	}
+}
+
+// Generate 签发access token和refresh token, MaxRefresh为0时不签发refresh token
+func (i *Issuer) Generate(id *Identity) (*TokenPair, error) {
+	now := time.Now()
+	access, err := i.sign(id, TokenTypeAccess, now, i.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	pair := &TokenPair{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresAt:   now.Add(i.Timeout),
+	}
+	if i.MaxRefresh > 0 {
+		if pair.RefreshToken, err = i.sign(id, TokenTypeRefresh, now, i.MaxRefresh); err != nil {
+			return nil, err
+		}
+		pair.RefreshExpiresAt = now.Add(i.MaxRefresh)
+	}
+	return pair, nil
+}
+
+// Refresh 校验refresh token并签发新的token
+// roleVersion不为空且与token中的不一致时返回ErrRoleChanged
+func (i *Issuer) Refresh(refreshToken, roleVersion string) (*TokenPair, error) {
+	claims, err := i.Parse(refreshToken, TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	if roleVersion != "" && claims.RoleVersion != roleVersion {
+		return nil, ErrRoleChanged
+	}
+	return i.Generate(&Identity{
+		Subject:     claims.Subject,
+		Roles:       claims.Roles,
+		RoleVersion: claims.RoleVersion,
+		Extra:       claims.Extra,
+	})
+}
+
+func (i *Issuer) sign(id *Identity, typ string, now time.Time, ttl time.Duration) (string, error) {
+	if i.KeyRing == nil {
+		return "", ErrNoKeyRing
+	}
+	key, err := i.KeyRing.Active()
+	if err != nil {
+		return "", err
+	}
+	alg := i.Algorithm
+	if alg == "" {
+		if alg, err = key.Algorithm(); err != nil {
+			return "", err
+		}
+	}
+	// 校验算法与密钥类型匹配
+	if _, err := key.Signer(alg); err != nil {
+		return "", err
+	}
+	method := jwt.GetSigningMethod(string(alg))
+	if method == nil {
+		return "", fmt.Errorf("%w: %s", rsa.ErrUnsupportedAlgorithm, alg)
+	}
+
+	claims := &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newJTI(),
+			Issuer:    i.Issuer,
+			Subject:   id.Subject,
+			Audience:  i.Audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Type:        typ,
+		Roles:       id.Roles,
+		RoleVersion: id.RoleVersion,
+		Extra:       id.Extra,
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(signingKey(key))
+}
+
+// Parse 验证签名、exp、nbf、iss、aud和token类型
+func (i *Issuer) Parse(tokenString, typ string) (*Claims, error) {
+	if i.KeyRing == nil {
+		return nil, ErrNoKeyRing
+	}
+	claims := &Claims{}
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{
+		string(rsa.RS256), string(rsa.RS384), string(rsa.RS512),
+		string(rsa.PS256), string(rsa.PS384), string(rsa.PS512),
+		string(rsa.ES256), string(rsa.ES384), string(rsa.EdDSA),
+	}))
+	_, err := parser.ParseWithClaims(tokenString, claims, i.keyFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.Issuer != "" && !claims.VerifyIssuer(i.Issuer, true) {
+		return nil, jwt.ErrTokenInvalidIssuer
+	}
+	if len(i.Audience) > 0 {
+		ok := false
+		for _, aud := range i.Audience {
+			if claims.VerifyAudience(aud, true) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return nil, jwt.ErrTokenInvalidAudience
+		}
+	}
+	if typ != "" && claims.Type != typ {
+		return nil, ErrTokenType
+	}
+	return claims, nil
+}
+
+// keyFunc 按header中的kid查找公钥, 密钥指定了Alg时token的alg必须一致
+func (i *Issuer) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := i.KeyRing.Get(kid)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	alg := rsa.SignAlgorithm(token.Method.Alg())
+	if key.Alg != "" && key.Alg != alg {
+		return nil, fmt.Errorf("%w: %s for key %s", rsa.ErrUnsupportedAlgorithm, alg, kid)
+	}
+	if _, err := key.Verifier(alg); err != nil {
+		return nil, err
+	}
+	return key.PublicKey(), nil
+}
+
+// signingKey jwt库需要的私钥类型: RSA为*rsa.PrivateKey, 其他为crypto.Signer
+func signingKey(key *rsa.Key) crypto.Signer {
+	if key.Private != nil {
+		return key.Private
+	}
+	return key.SigningKey
+}
+
+func newJTI() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
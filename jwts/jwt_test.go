@@ -0,0 +1,142 @@
+package jwts
+
+import (
+	"crypto/rand"
+	gorsa "crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/lflxp/tools/httpclient"
+	"github.com/lflxp/tools/rsa"
+)
+
+func newTestRing(t *testing.T) *rsa.KeyRing {
+	priv, err := gorsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed, err := rsa.GenerateSigningKey(rsa.EdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ring := rsa.NewKeyRing()
+	if err := ring.Add(&rsa.Key{ID: "rsa", Private: priv, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Add(&rsa.Key{ID: "ed", SigningKey: ed}); err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+func Test_IssuerAlgorithms(t *testing.T) {
+	ring := newTestRing(t)
+	issuer := NewIssuer(ring, "lflxp", "tools")
+
+	cases := []struct {
+		kid string
+		alg rsa.SignAlgorithm
+	}{
+		{"rsa", rsa.RS256},
+		{"rsa", rsa.PS256},
+		{"ed", rsa.EdDSA},
+	}
+	for _, c := range cases {
+		if err := ring.SetActive(c.kid); err != nil {
+			t.Fatal(err)
+		}
+		issuer.Algorithm = c.alg
+		pair, err := issuer.Generate(&Identity{Subject: "admin", Roles: []string{"admin"}})
+		if err != nil {
+			t.Fatalf("%s: %v", c.alg, err)
+		}
+		claims, err := issuer.Parse(pair.AccessToken, TokenTypeAccess)
+		if err != nil {
+			t.Fatalf("%s: %v", c.alg, err)
+		}
+		if claims.Subject != "admin" || claims.Roles[0] != "admin" {
+			t.Fatalf("%s: unexpected claims %+v", c.alg, claims)
+		}
+		if _, err := issuer.Parse(pair.RefreshToken, TokenTypeAccess); !errors.Is(err, ErrTokenType) {
+			t.Fatalf("%s: expected ErrTokenType, got %v", c.alg, err)
+		}
+	}
+
+	// 算法与密钥类型不匹配
+	issuer.Algorithm = rsa.RS256
+	if _, err := issuer.Generate(&Identity{Subject: "admin"}); err == nil {
+		t.Fatal("expected error for RS256 with ed25519 key")
+	}
+
+	// aud不匹配
+	issuer.Algorithm = ""
+	pair, _ := issuer.Generate(&Identity{Subject: "admin"})
+	other := NewIssuer(ring, "lflxp", "other")
+	if _, err := other.Parse(pair.AccessToken, TokenTypeAccess); !errors.Is(err, jwt.ErrTokenInvalidAudience) {
+		t.Fatalf("expected ErrTokenInvalidAudience, got %v", err)
+	}
+
+	// 密钥被移除后无法验证
+	ring.Remove("ed")
+	if _, err := issuer.Parse(pair.AccessToken, TokenTypeAccess); err == nil {
+		t.Fatal("expected error for removed key")
+	}
+}
+
+func Test_MiddlewareFunc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	roleVersion := "1"
+	mw := &GinJWTMiddleware{
+		Issuer: NewIssuer(newTestRing(t), "lflxp"),
+		RoleVersion: func(string) (string, error) {
+			return roleVersion, nil
+		},
+		Authorizator: func(claims *Claims, c *gin.Context) bool {
+			return len(claims.Roles) > 0 && claims.Roles[0] == "admin"
+		},
+	}
+	router := gin.New()
+	router.GET("/api", mw.MiddlewareFunc(), func(c *gin.Context) {
+		httpclient.SendSuccessMessage(c, http.StatusOK, GetIdentity(c))
+	})
+
+	do := func(token string) (int, httpclient.Result) {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var result httpclient.Result
+		_ = json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result
+	}
+
+	if code, result := do(""); code != http.StatusUnauthorized || result.ErrorCode != httpclient.AthorizationError {
+		t.Fatalf("unexpected %d %+v", code, result)
+	}
+
+	admin, _ := mw.Generate(&Identity{Subject: "admin", Roles: []string{"admin"}, RoleVersion: "1"})
+	if code, result := do(admin.AccessToken); code != http.StatusOK || result.Data != "admin" {
+		t.Fatalf("unexpected %d %+v", code, result)
+	}
+
+	guest, _ := mw.Generate(&Identity{Subject: "guest", RoleVersion: "1"})
+	if code, result := do(guest.AccessToken); code != http.StatusForbidden || result.ErrorCode != httpclient.BiddenError {
+		t.Fatalf("unexpected %d %+v", code, result)
+	}
+
+	roleVersion = "2"
+	if code, result := do(admin.AccessToken); code != http.StatusUnauthorized || result.ErrorCode != httpclient.RoleChangeNeedReLogin {
+		t.Fatalf("unexpected %d %+v", code, result)
+	}
+	if _, err := mw.Refresh(admin.RefreshToken, roleVersion); !errors.Is(err, ErrRoleChanged) {
+		t.Fatalf("expected ErrRoleChanged, got %v", err)
+	}
+}
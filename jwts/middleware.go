@@ -0,0 +1,207 @@
+package jwts
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/httpclient"
+	"github.com/lflxp/tools/logging"
+)
+
+var logger = logging.For("jwts")
+
+// gin context中保存身份信息的key
+const (
+	IdentityKey = "identity"
+	ClaimsKey   = "JWT_PAYLOAD"
+)
+
+// GinJWTMiddleware 签发和验证token的gin中间件
+type GinJWTMiddleware struct {
+	*Issuer
+
+	// TokenHeadName Authorization头中的前缀, 默认Bearer
+	TokenHeadName string
+	// TokenQuery 从url参数获取token, 用于websocket等无法设置header的场景, 为空时不启用
+	TokenQuery string
+
+	// Authenticator 登录时校验用户, 返回签发token的身份信息
+	Authenticator func(c *gin.Context) (*Identity, error)
+	// Authorizator 鉴权, 返回false时响应BiddenError
+	Authorizator func(claims *Claims, c *gin.Context) bool
+	// RoleVersion 返回用户当前的角色版本, 与token中的不一致时需要重新登录
+	RoleVersion func(subject string) (string, error)
+}
+
+var (
+	defaultLock       sync.RWMutex
+	defaultMiddleware = &GinJWTMiddleware{}
+)
+
+// Init 设置全局默认中间件, NewGinJwtMiddlewares和GetMiddleware基于它创建
+func Init(issuer *Issuer) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultMiddleware = &GinJWTMiddleware{Issuer: issuer}
+}
+
+// GetMiddleware 返回全局默认中间件
+func GetMiddleware() *GinJWTMiddleware {
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+	return defaultMiddleware
+}
+
+// NewGinJwtMiddlewares 基于全局配置创建带鉴权函数的中间件
+func NewGinJwtMiddlewares(authorizator func(claims *Claims, c *gin.Context) bool) *GinJWTMiddleware {
+	mw := *GetMiddleware()
+	mw.Authorizator = authorizator
+	return &mw
+}
+
+// MiddlewareFunc 验证token, 通过后将subject和claims写入gin context
+func (mw *GinJWTMiddleware) MiddlewareFunc() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := mw.token(c)
+		if token == "" {
+			httpclient.SendErrorMessage(c, http.StatusUnauthorized, httpclient.AthorizationError, "")
+			c.Abort()
+			return
+		}
+		if mw.Issuer == nil {
+			logger.Error("jwt middleware not initialized")
+			httpclient.SendErrorMessage(c, http.StatusInternalServerError, httpclient.SystemError, ErrNoKeyRing.Error())
+			c.Abort()
+			return
+		}
+
+		claims, err := mw.Parse(token, TokenTypeAccess)
+		if err != nil {
+			logger.DebugContext(c.Request.Context(), "jwt verify failed", "path", c.Request.URL.Path, "error", err)
+			httpclient.SendErrorMessage(c, http.StatusUnauthorized, httpclient.AthorizationError, err.Error())
+			c.Abort()
+			return
+		}
+
+		if mw.RoleVersion != nil {
+			rv, err := mw.RoleVersion(claims.Subject)
+			if err != nil {
+				logger.ErrorContext(c.Request.Context(), "jwt role version", "subject", claims.Subject, "error", err)
+				httpclient.SendErrorMessage(c, http.StatusInternalServerError, httpclient.SystemError, err.Error())
+				c.Abort()
+				return
+			}
+			if rv != claims.RoleVersion {
+				httpclient.SendErrorMessage(c, http.StatusUnauthorized, httpclient.RoleChangeNeedReLogin, "")
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set(IdentityKey, claims.Subject)
+		c.Set(ClaimsKey, claims)
+
+		if mw.Authorizator != nil && !mw.Authorizator(claims, c) {
+			httpclient.SendErrorMessage(c, http.StatusForbidden, httpclient.BiddenError, "")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// LoginHandler 调用Authenticator校验用户并签发token
+func (mw *GinJWTMiddleware) LoginHandler(c *gin.Context) {
+	if mw.Authenticator == nil || mw.Issuer == nil {
+		httpclient.SendErrorMessage(c, http.StatusInternalServerError, httpclient.SystemError, "jwt authenticator not configured")
+		return
+	}
+	id, err := mw.Authenticator(c)
+	if err != nil {
+		httpclient.SendErrorMessage(c, http.StatusUnauthorized, httpclient.AthorizationError, err.Error())
+		return
+	}
+	pair, err := mw.Generate(id)
+	if err != nil {
+		logger.ErrorContext(c.Request.Context(), "jwt generate", "subject", id.Subject, "error", err)
+		httpclient.SendErrorMessage(c, http.StatusInternalServerError, httpclient.SystemError, err.Error())
+		return
+	}
+	httpclient.SendSuccessMessage(c, http.StatusOK, pair)
+}
+
+// RefreshHandler 使用refresh token换取新token, refresh token从json body的refresh_token或header获取
+func (mw *GinJWTMiddleware) RefreshHandler(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" form:"refresh_token"`
+	}
+	_ = c.ShouldBind(&req)
+	if req.RefreshToken == "" {
+		req.RefreshToken = mw.token(c)
+	}
+	if req.RefreshToken == "" {
+		httpclient.SendErrorMessage(c, http.StatusUnauthorized, httpclient.AthorizationError, "")
+		return
+	}
+	if mw.Issuer == nil {
+		httpclient.SendErrorMessage(c, http.StatusInternalServerError, httpclient.SystemError, ErrNoKeyRing.Error())
+		return
+	}
+
+	var rv string
+	if mw.RoleVersion != nil {
+		claims, err := mw.Parse(req.RefreshToken, TokenTypeRefresh)
+		if err != nil {
+			httpclient.SendErrorMessage(c, http.StatusUnauthorized, httpclient.AthorizationError, err.Error())
+			return
+		}
+		if rv, err = mw.RoleVersion(claims.Subject); err != nil {
+			httpclient.SendErrorMessage(c, http.StatusInternalServerError, httpclient.SystemError, err.Error())
+			return
+		}
+	}
+
+	pair, err := mw.Refresh(req.RefreshToken, rv)
+	if errors.Is(err, ErrRoleChanged) {
+		httpclient.SendErrorMessage(c, http.StatusUnauthorized, httpclient.RoleChangeNeedReLogin, "")
+		return
+	} else if err != nil {
+		httpclient.SendErrorMessage(c, http.StatusUnauthorized, httpclient.AthorizationError, err.Error())
+		return
+	}
+	httpclient.SendSuccessMessage(c, http.StatusOK, pair)
+}
+
+func (mw *GinJWTMiddleware) token(c *gin.Context) string {
+	head := mw.TokenHeadName
+	if head == "" {
+		head = "Bearer"
+	}
+	auth := c.GetHeader("Authorization")
+	if len(auth) > len(head)+1 && strings.EqualFold(auth[:len(head)], head) && auth[len(head)] == ' ' {
+		return strings.TrimSpace(auth[len(head)+1:])
+	}
+	if mw.TokenQuery != "" {
+		return c.Query(mw.TokenQuery)
+	}
+	return ""
+}
+
+// ExtractClaims 获取MiddlewareFunc写入的claims
+func ExtractClaims(c *gin.Context) *Claims {
+	if v, ok := c.Get(ClaimsKey); ok {
+		if claims, ok := v.(*Claims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+// GetIdentity 获取当前用户的subject
+func GetIdentity(c *gin.Context) string {
+	return c.GetString(IdentityKey)
+}
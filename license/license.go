@@ -0,0 +1,195 @@
+package license
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lflxp/tools/rsa"
+	"github.com/lflxp/tools/utils"
+)
+
+var (
+	ErrSignature   = errors.New("license: invalid signature")
+	ErrExpired     = errors.New("license: expired")
+	ErrNotYetValid = errors.New("license: not yet valid")
+	ErrMachine     = errors.New("license: not issued for this machine")
+	ErrMaxNodes    = errors.New("license: node limit exceeded")
+	ErrFeature     = errors.New("license: feature not licensed")
+	ErrNoLicense   = errors.New("license: not loaded")
+	ErrLicenseFile = errors.New("license: invalid file")
+	ErrNoExpiry    = errors.New("license: exactly one of ExpiresAt and Perpetual must be set")
+)
+
+// License 授权信息, MACs、Hosts和Fingerprints为空时不绑定机器, MaxNodes为0时不限制节点数
+// ExpiresAt和Perpetual必须且只能设置一个, 避免漏填有效期变成永久授权
+type License struct {
+	ID        string    `json:"id"`
+	Customer  string    `json:"customer"`
+	IssuedAt  time.Time `json:"issuedAt"`
+	NotBefore time.Time `json:"notBefore,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Perpetual bool      `json:"perpetual,omitempty"`
+	Features  []string  `json:"features,omitempty"`
+	MaxNodes  int       `json:"maxNodes,omitempty"`
+	MACs      []string  `json:"macs,omitempty"`
+	Hosts     []string  `json:"hosts,omitempty"`
+	// Fingerprints utils.HostFingerprint 的结果
+	Fingerprints []string `json:"fingerprints,omitempty"`
+}
+
+// file license文件格式, payload为License的json, 签名使用KeyRing的签名格式(包含kid和算法)
+type file struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// Machine 当前机器的指纹
+type Machine struct {
+	MACs        []string
+	Hostname    string
+	Fingerprint string
+}
+
+// CurrentMachine 读取本机的MAC地址、主机名和主机指纹
+func CurrentMachine() *Machine {
+	m := &Machine{}
+	for _, addr := range utils.GetMacAddrs() {
+		// 格式为 mac,网卡名
+		m.MACs = append(m.MACs, strings.SplitN(addr, ",", 2)[0])
+	}
+	m.Hostname, _ = os.Hostname()
+	if fp, err := utils.HostFingerprint(); err == nil {
+		m.Fingerprint = fp
+	}
+	return m
+}
+
+// Issue 使用KeyRing的active私钥签发license文件, ID和IssuedAt为空时自动生成, 不修改lic
+func Issue(license *License, ring *rsa.KeyRing) ([]byte, error) {
+	if err := license.checkExpiry(); err != nil {
+		return nil, err
+	}
+	lic := *license
+	lic.MACs = append([]string(nil), license.MACs...)
+	if lic.ID == "" {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		lic.ID = hex.EncodeToString(b)
+	}
+	if lic.IssuedAt.IsZero() {
+		lic.IssuedAt = time.Now().UTC()
+	}
+	for i, mac := range lic.MACs {
+		lic.MACs[i] = strings.ToLower(mac)
+	}
+
+	payload, err := json.Marshal(&lic)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ring.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&file{Payload: payload, Signature: sig}, "", "  ")
+}
+
+// Parse 验证签名并解析license, 不检查有效期和机器, 需要再调用Validate
+func Parse(data []byte, ring *rsa.KeyRing) (*License, error) {
+	f := &file{}
+	if err := json.Unmarshal(data, f); err != nil || len(f.Payload) == 0 {
+		return nil, ErrLicenseFile
+	}
+	if err := ring.Verify(f.Payload, f.Signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+	lic := &License{}
+	if err := json.Unmarshal(f.Payload, lic); err != nil {
+		return nil, ErrLicenseFile
+	}
+	return lic, nil
+}
+
+// ParseFile 读取并验证license文件
+func ParseFile(path string, ring *rsa.KeyRing) (*License, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, ring)
+}
+
+func (l *License) checkExpiry() error {
+	if l.ExpiresAt.IsZero() != l.Perpetual {
+		return ErrNoExpiry
+	}
+	return nil
+}
+
+// Validate 检查有效期和机器绑定, machine为nil时不检查机器
+func (l *License) Validate(now time.Time, machine *Machine) error {
+	if err := l.checkExpiry(); err != nil {
+		return err
+	}
+	if !l.NotBefore.IsZero() && now.Before(l.NotBefore) {
+		return ErrNotYetValid
+	}
+	if !l.Perpetual && !now.Before(l.ExpiresAt) {
+		return fmt.Errorf("%w at %s", ErrExpired, l.ExpiresAt.Format(time.RFC3339))
+	}
+	if machine != nil && !l.matchMachine(machine) {
+		return ErrMachine
+	}
+	return nil
+}
+
+// matchMachine 任一MAC、主机名或指纹匹配即可
+func (l *License) matchMachine(m *Machine) bool {
+	if len(l.MACs) == 0 && len(l.Hosts) == 0 && len(l.Fingerprints) == 0 {
+		return true
+	}
+	for _, fp := range l.Fingerprints {
+		if m.Fingerprint != "" && fp == m.Fingerprint {
+			return true
+		}
+	}
+	for _, allowed := range l.MACs {
+		for _, mac := range m.MACs {
+			if strings.EqualFold(allowed, mac) {
+				return true
+			}
+		}
+	}
+	for _, host := range l.Hosts {
+		if host == m.Hostname {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFeature 是否授权了功能
+func (l *License) HasFeature(feature string) bool {
+	for _, f := range l.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckNodes 检查节点数是否超过授权
+func (l *License) CheckNodes(nodes int) error {
+	if l.MaxNodes > 0 && nodes > l.MaxNodes {
+		return fmt.Errorf("%w: %d > %d", ErrMaxNodes, nodes, l.MaxNodes)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package license
+
+import (
+	"crypto/rand"
+	gorsa "crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/httpclient"
+	"github.com/lflxp/tools/rsa"
+)
+
+func newTestRings(t *testing.T) (issuer, verifier *rsa.KeyRing) {
+	priv, err := gorsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer = rsa.NewKeyRing()
+	if err := issuer.Add(&rsa.Key{ID: "license", Private: priv, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	// 客户环境只有公钥
+	verifier = rsa.NewKeyRing()
+	if err := verifier.Add(&rsa.Key{ID: "license", Public: &priv.PublicKey}); err != nil {
+		t.Fatal(err)
+	}
+	return issuer, verifier
+}
+
+func Test_IssueAndValidate(t *testing.T) {
+	issuer, verifier := newTestRings(t)
+	now := time.Now()
+	input := &License{
+		Customer:  "lflxp",
+		ExpiresAt: now.Add(time.Hour),
+		Features:  []string{"proxy"},
+		MaxNodes:  3,
+		MACs:      []string{"00:CF:E0:44:DD:BE"},
+	}
+	data, err := Issue(input, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 不修改调用方的License
+	if input.ID != "" || !input.IssuedAt.IsZero() || input.MACs[0] != "00:CF:E0:44:DD:BE" {
+		t.Fatalf("input modified %+v", input)
+	}
+
+	lic, err := Parse(data, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lic.Validate(now, &Machine{MACs: []string{"00:cf:e0:44:dd:be"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lic.Validate(now, &Machine{MACs: []string{"11:11:11:11:11:11"}, Hostname: "other"}); !errors.Is(err, ErrMachine) {
+		t.Fatalf("expected ErrMachine, got %v", err)
+	}
+	lic.Fingerprints = []string{"fp"}
+	if err := lic.Validate(now, &Machine{MACs: []string{"11:11:11:11:11:11"}, Fingerprint: "fp"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lic.Validate(now.Add(2*time.Hour), nil); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	if err := lic.CheckNodes(4); !errors.Is(err, ErrMaxNodes) {
+		t.Fatalf("expected ErrMaxNodes, got %v", err)
+	}
+	if !lic.HasFeature("proxy") || lic.HasFeature("cluster") {
+		t.Fatal("unexpected features")
+	}
+
+	// 没有有效期时必须显式设置Perpetual
+	if _, err := Issue(&License{Customer: "lflxp"}, issuer); !errors.Is(err, ErrNoExpiry) {
+		t.Fatalf("expected ErrNoExpiry, got %v", err)
+	}
+	if _, err := Issue(&License{Customer: "lflxp", ExpiresAt: now, Perpetual: true}, issuer); !errors.Is(err, ErrNoExpiry) {
+		t.Fatalf("expected ErrNoExpiry, got %v", err)
+	}
+	data, err = Issue(&License{Customer: "lflxp", Perpetual: true}, issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perpetual, err := Parse(data, verifier); err != nil || perpetual.Validate(now.Add(100*365*24*time.Hour), nil) != nil {
+		t.Fatalf("perpetual license %+v %v", perpetual, err)
+	}
+	if err := (&License{}).Validate(now, nil); !errors.Is(err, ErrNoExpiry) {
+		t.Fatalf("expected ErrNoExpiry, got %v", err)
+	}
+
+	// 修改payload后签名失效
+	f := &file{}
+	_ = json.Unmarshal(data, f)
+	f.Payload = []byte(`{"customer":"other","expiresAt":"2099-01-01T00:00:00Z"}`)
+	tampered, _ := json.Marshal(f)
+	if _, err := Parse(tampered, verifier); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected ErrSignature, got %v", err)
+	}
+}
+
+func Test_CheckerMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	issuer, verifier := newTestRings(t)
+	checker := NewChecker(verifier, 24*time.Hour)
+	checker.Machine = nil
+
+	router := gin.New()
+	router.Use(checker.Middleware())
+	router.GET("/api", checker.RequireFeature("proxy"), func(c *gin.Context) {
+		httpclient.SendSuccessMessage(c, http.StatusOK, "ok")
+	})
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api", nil))
+		return w
+	}
+
+	if w := do(); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without license, got %d", w.Code)
+	}
+
+	valid, _ := Issue(&License{Customer: "lflxp", ExpiresAt: time.Now().Add(time.Hour), Features: []string{"proxy"}}, issuer)
+	if err := checker.Load(valid); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(); w.Code != http.StatusOK || w.Header().Get(HeaderWarning) != "" {
+		t.Fatalf("unexpected %d %v", w.Code, w.Header())
+	}
+
+	// 节点数超过授权时拒绝
+	nodes := 2
+	checker.Nodes = func() (int, error) { return nodes, nil }
+	limited, _ := Issue(&License{Customer: "lflxp", ExpiresAt: time.Now().Add(time.Hour), Features: []string{"proxy"}, MaxNodes: 2}, issuer)
+	if err := checker.Load(limited); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 within node limit, got %d", w.Code)
+	}
+	nodes = 3
+	if w := do(); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 over node limit, got %d", w.Code)
+	}
+	checker.Nodes = nil
+
+	// 重新加载失败时保留原license
+	if err := checker.Load([]byte("broken")); err == nil {
+		t.Fatal("expected parse error")
+	}
+	if w := do(); w.Code != http.StatusOK || checker.License() == nil {
+		t.Fatalf("valid license replaced by failed reload, got %d", w.Code)
+	}
+
+	grace, _ := Issue(&License{Customer: "lflxp", ExpiresAt: time.Now().Add(-time.Hour), Features: []string{"proxy"}}, issuer)
+	_ = checker.Load(grace)
+	if w := do(); w.Code != http.StatusOK || w.Header().Get(HeaderWarning) == "" {
+		t.Fatalf("expected grace period, got %d %v", w.Code, w.Header())
+	}
+
+	expired, _ := Issue(&License{Customer: "lflxp", ExpiresAt: time.Now().Add(-48 * time.Hour)}, issuer)
+	_ = checker.Load(expired)
+	w := do()
+	var result httpclient.Result
+	_ = json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusForbidden || result.ErrorCode != httpclient.LicenseError {
+		t.Fatalf("unexpected %d %+v", w.Code, result)
+	}
+}
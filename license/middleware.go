@@ -0,0 +1,154 @@
+package license
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/httpclient"
+	"github.com/lflxp/tools/logging"
+	"github.com/lflxp/tools/rsa"
+)
+
+var logger = logging.For("license")
+
+// 校验状态
+const (
+	StateValid   = "valid"
+	StateGrace   = "grace"
+	StateInvalid = "invalid"
+)
+
+// HeaderWarning 宽限期内响应头中的提示
+const HeaderWarning = "X-License-Warning"
+
+// Status license校验结果
+type Status struct {
+	State   string    `json:"state"`
+	License *License  `json:"license,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	GraceTo time.Time `json:"graceTo,omitempty"`
+}
+
+// Checker 缓存已验证的license, 每次请求按当前时间检查有效期
+// Grace大于0时, 过期后的宽限期内仍然放行, 只返回警告
+// Nodes不为nil时同时检查节点数, 每次请求都会调用, 需要自行缓存
+type Checker struct {
+	Ring    *rsa.KeyRing
+	Grace   time.Duration
+	Machine *Machine
+	Nodes   func() (int, error)
+
+	lock    sync.RWMutex
+	license *License
+	err     error
+}
+
+// NewChecker 默认绑定当前机器
+func NewChecker(ring *rsa.KeyRing, grace time.Duration) *Checker {
+	return &Checker{Ring: ring, Grace: grace, Machine: CurrentMachine(), err: ErrNoLicense}
+}
+
+// Load 验证签名并替换当前license
+// 已有有效签名的license时, 加载失败只返回错误, 继续使用原license
+func (c *Checker) Load(data []byte) error {
+	lic, err := Parse(data, c.Ring)
+	c.set(lic, err)
+	return err
+}
+
+// LoadFile 读取license文件, 失败时同Load
+func (c *Checker) LoadFile(path string) error {
+	lic, err := ParseFile(path, c.Ring)
+	c.set(lic, err)
+	return err
+}
+
+func (c *Checker) set(lic *License, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err != nil {
+		if c.license == nil {
+			c.err = err
+		}
+		return
+	}
+	c.license, c.err = lic, nil
+}
+
+// License 返回当前license, 未加载或签名错误时返回nil
+func (c *Checker) License() *License {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.license
+}
+
+// Check 检查当前license状态
+func (c *Checker) Check(now time.Time) Status {
+	c.lock.RLock()
+	lic, err := c.license, c.err
+	c.lock.RUnlock()
+	if err != nil {
+		return Status{State: StateInvalid, Error: err.Error()}
+	}
+
+	// 节点数超限不适用宽限期
+	if c.Nodes != nil {
+		nodes, err := c.Nodes()
+		if err == nil {
+			err = lic.CheckNodes(nodes)
+		}
+		if err != nil {
+			return Status{State: StateInvalid, License: lic, Error: err.Error()}
+		}
+	}
+
+	err = lic.Validate(now, c.Machine)
+	switch {
+	case err == nil:
+		return Status{State: StateValid, License: lic}
+	case errors.Is(err, ErrExpired) && c.Grace > 0 && now.Before(lic.ExpiresAt.Add(c.Grace)):
+		return Status{State: StateGrace, License: lic, Error: err.Error(), GraceTo: lic.ExpiresAt.Add(c.Grace)}
+	default:
+		return Status{State: StateInvalid, License: lic, Error: err.Error()}
+	}
+}
+
+// Middleware license无效或过期时响应LicenseError, 宽限期内放行并设置X-License-Warning
+func (c *Checker) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		status := c.Check(time.Now())
+		switch status.State {
+		case StateValid:
+		case StateGrace:
+			logger.WarnContext(ctx.Request.Context(), "license in grace period", "error", status.Error, "graceTo", status.GraceTo)
+			ctx.Header(HeaderWarning, "license expired, grace period until "+status.GraceTo.Format(time.RFC3339))
+		default:
+			httpclient.SendErrorMessage(ctx, http.StatusForbidden, httpclient.LicenseError, status.Error)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// RequireFeature 要求license授权了指定功能, 需要放在Middleware之后
+func (c *Checker) RequireFeature(feature string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		lic := c.License()
+		if lic == nil || !lic.HasFeature(feature) {
+			httpclient.SendErrorMessage(ctx, http.StatusForbidden, httpclient.LicenseError, ErrFeature.Error()+": "+feature)
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// StatusHandler 返回当前license状态
+func (c *Checker) StatusHandler(ctx *gin.Context) {
+	httpclient.SendSuccessMessage(ctx, http.StatusOK, c.Check(time.Now()))
+}
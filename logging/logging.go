@@ -0,0 +1,321 @@
+// Package logging 统一的slog配置
+//
+// 各个包通过 logging.For("rsa") 获取logger, Setup之前输出到slog.Default(),
+// Setup之后使用统一的格式、按包设置的级别和敏感字段脱敏, 不需要重新获取logger
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// 输出格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// 日志中的字段名
+const (
+	PackageKey = "pkg"
+	TraceIDKey = "trace_id"
+)
+
+// Redacted 脱敏后的值
+const Redacted = "[REDACTED]"
+
+// DefaultRedactKeys key中包含这些词(不区分大小写)时脱敏
+var DefaultRedactKeys = []string{"password", "passwd", "secret", "token", "apikey", "api_key", "authorization", "cookie", "passphrase", "private"}
+
+// Config 日志配置
+type Config struct {
+	// Format text或json, 默认text
+	Format string
+	// Level 默认级别 debug/info/warn/error, 默认info
+	Level string
+	// Levels 按包设置级别, key为For的包名, 如 {"sdk/clientgo": "warn"}
+	// 没有配置的子包使用上级包的级别, 如 sdk/clientgo/model 使用 sdk/clientgo
+	Levels map[string]string
+	// AddSource 输出调用位置
+	AddSource bool
+	// Output 默认os.Stderr, 需要写文件时使用utils.NewRotateWriter
+	Output io.Writer
+	// RedactKeys 追加的脱敏字段
+	RedactKeys []string
+}
+
+// state Setup后的配置, 替换时整体替换
+type state struct {
+	handler slog.Handler
+	level   slog.Level
+	levels  map[string]slog.Level
+}
+
+var current atomic.Pointer[state]
+
+// Setup 配置全局日志并替换slog.Default, 可以重复调用
+func Setup(cfg Config) error {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	levels := map[string]slog.Level{}
+	for pkg, l := range cfg.Levels {
+		if levels[pkg], err = ParseLevel(l); err != nil {
+			return fmt.Errorf("level of %s: %w", pkg, err)
+		}
+	}
+
+	out := cfg.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	opts := &slog.HandlerOptions{
+		AddSource:   cfg.AddSource,
+		Level:       slog.Level(-8), // 级别由handler.Enabled判断
+		ReplaceAttr: redactAttr(append(append([]string{}, DefaultRedactKeys...), cfg.RedactKeys...)),
+	}
+	var h slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", FormatText:
+		h = slog.NewTextHandler(out, opts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(out, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+
+	current.Store(&state{handler: h, level: level, levels: levels})
+	slog.SetDefault(slog.New(&handler{}))
+	return nil
+}
+
+// SetLevel 运行时修改级别, pkg为空时修改默认级别, 需要先Setup
+func SetLevel(pkg, level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	old := current.Load()
+	if old == nil {
+		return fmt.Errorf("logging not configured")
+	}
+	st := &state{handler: old.handler, level: old.level, levels: map[string]slog.Level{}}
+	for k, v := range old.levels {
+		st.levels[k] = v
+	}
+	if pkg == "" {
+		st.level = l
+	} else {
+		st.levels[pkg] = l
+	}
+	current.Store(st)
+	return nil
+}
+
+// ParseLevel 解析级别, 为空时为info
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+// ParseLevels 解析 "info,rsa=debug,sdk/clientgo=warn" 格式, 第一个不带包名的为默认级别
+func ParseLevels(s string) (level string, levels map[string]string) {
+	levels = map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if pkg, l, ok := strings.Cut(item, "="); ok {
+			levels[strings.TrimSpace(pkg)] = strings.TrimSpace(l)
+		} else {
+			level = item
+		}
+	}
+	return level, levels
+}
+
+// ConfigFromEnv 从环境变量读取配置
+// LOG_LEVEL=info,rsa=debug LOG_FORMAT=json LOG_SOURCE=true
+func ConfigFromEnv() Config {
+	cfg := Config{Format: os.Getenv("LOG_FORMAT")}
+	cfg.Level, cfg.Levels = ParseLevels(os.Getenv("LOG_LEVEL"))
+	switch strings.ToLower(os.Getenv("LOG_SOURCE")) {
+	case "1", "true", "yes":
+		cfg.AddSource = true
+	}
+	return cfg
+}
+
+// For 返回包的logger, 日志带有pkg字段, 可以在包初始化时调用
+func For(pkg string) *slog.Logger {
+	return slog.New(&handler{pkg: pkg})
+}
+
+func (s *state) levelFor(pkg string) slog.Level {
+	for pkg != "" {
+		if l, ok := s.levels[pkg]; ok {
+			return l
+		}
+		i := strings.LastIndex(pkg, "/")
+		if i < 0 {
+			break
+		}
+		pkg = pkg[:i]
+	}
+	return s.level
+}
+
+// redactAttr key包含敏感词时替换为Redacted
+func redactAttr(keys []string) func(groups []string, a slog.Attr) slog.Attr {
+	for i, k := range keys {
+		keys[i] = strings.ToLower(k)
+	}
+	return func(groups []string, a slog.Attr) slog.Attr {
+		if a.Value.Kind() == slog.KindGroup {
+			return a
+		}
+		key := strings.ToLower(a.Key)
+		for _, k := range keys {
+			if strings.Contains(key, k) {
+				return slog.String(a.Key, Redacted)
+			}
+		}
+		return a
+	}
+}
+
+// handler 每次输出时使用当前的配置, Setup前后获取的logger行为一致
+type handler struct {
+	pkg     string
+	traceID string
+	ops     []func(slog.Handler) slog.Handler
+	cache   atomic.Pointer[resolved]
+}
+
+type resolved struct {
+	state   *state
+	handler slog.Handler
+}
+
+func (h *handler) resolve() (slog.Handler, *state) {
+	st := current.Load()
+	if st == nil {
+		// Setup之前同样按DefaultRedactKeys脱敏
+		replace := redactAttr(append([]string{}, DefaultRedactKeys...))
+		base := slog.Default().Handler()
+		if _, ok := base.(*handler); ok {
+			base = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{ReplaceAttr: replace})
+		} else {
+			base = &redactHandler{Handler: base, replace: replace}
+		}
+		return h.apply(base), nil
+	}
+	if c := h.cache.Load(); c != nil && c.state == st {
+		return c.handler, st
+	}
+	hh := h.apply(st.handler)
+	h.cache.Store(&resolved{state: st, handler: hh})
+	return hh, st
+}
+
+// redactHandler 包装没有ReplaceAttr的handler(如slog.Default), 在输出前脱敏
+type redactHandler struct {
+	slog.Handler
+	replace func(groups []string, a slog.Attr) slog.Attr
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, nr)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	return &redactHandler{Handler: h.Handler.WithAttrs(redacted), replace: h.replace}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name), replace: h.replace}
+}
+
+func (h *redactHandler) redact(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		return h.replace(nil, a)
+	}
+	group := a.Value.Group()
+	redacted := make([]slog.Attr, len(group))
+	for i, ga := range group {
+		redacted[i] = h.redact(ga)
+	}
+	return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+}
+
+func (h *handler) apply(base slog.Handler) slog.Handler {
+	if h.pkg != "" {
+		base = base.WithAttrs([]slog.Attr{slog.String(PackageKey, h.pkg)})
+	}
+	for _, op := range h.ops {
+		base = op(base)
+	}
+	return base
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	base, st := h.resolve()
+	if st == nil {
+		return base.Enabled(ctx, level)
+	}
+	return level >= st.levelFor(h.pkg)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	base, _ := h.resolve()
+	id := h.traceID
+	if id == "" {
+		id = TraceID(ctx)
+	}
+	if id != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String(TraceIDKey, id))
+	}
+	return base.Handle(ctx, r)
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{pkg: h.pkg, traceID: h.traceID, ops: append(ops, op)}
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(func(base slog.Handler) slog.Handler { return base.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(func(base slog.Handler) slog.Handler { return base.WithGroup(name) })
+}
@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupBuffer(t *testing.T, cfg Config) *bytes.Buffer {
+	old := slog.Default()
+	t.Cleanup(func() {
+		current.Store(nil)
+		slog.SetDefault(old)
+	})
+	buf := &bytes.Buffer{}
+	cfg.Output = buf
+	cfg.Format = FormatJSON
+	if err := Setup(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		m := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+func Test_PackageLevels(t *testing.T) {
+	// Setup之前获取的logger也使用新配置
+	rsaLogger := For("rsa")
+	modelLogger := For("sdk/clientgo/model")
+
+	level, levels := ParseLevels("info, rsa=debug, sdk/clientgo=warn")
+	buf := setupBuffer(t, Config{Level: level, Levels: levels})
+
+	rsaLogger.Debug("rsa debug")
+	modelLogger.Info("model info")
+	modelLogger.Warn("model warn")
+	slog.Debug("default debug")
+	slog.Info("default info")
+
+	got := lines(t, buf)
+	msgs := []string{}
+	for _, m := range got {
+		msgs = append(msgs, m["msg"].(string))
+	}
+	if strings.Join(msgs, ",") != "rsa debug,model warn,default info" {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+	if got[0][PackageKey] != "rsa" || got[1][PackageKey] != "sdk/clientgo/model" {
+		t.Fatalf("unexpected pkg %v", got)
+	}
+
+	if err := SetLevel("rsa", "error"); err != nil {
+		t.Fatal(err)
+	}
+	rsaLogger.Warn("rsa warn")
+	if len(lines(t, buf)) != 3 {
+		t.Fatal("SetLevel not applied")
+	}
+}
+
+func Test_Redact(t *testing.T) {
+	buf := setupBuffer(t, Config{RedactKeys: []string{"license"}})
+	For("test").With("apikey", "k1").WithGroup("req").Info("redact", "Password", "p1", "licenseKey", "l1", "user", "admin")
+
+	m := lines(t, buf)[0]
+	req := m["req"].(map[string]interface{})
+	if m["apikey"] != Redacted || req["Password"] != Redacted || req["licenseKey"] != Redacted || req["user"] != "admin" {
+		t.Fatalf("unexpected redaction %v", m)
+	}
+}
+
+func Test_RedactBeforeSetup(t *testing.T) {
+	old := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(old) })
+	buf := &bytes.Buffer{}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(buf, nil)))
+
+	For("test").With("apikey", "k1").WithGroup("req").Info("redact", "Password", "p1", slog.Group("auth", "token", "t1"), "user", "admin")
+	m := lines(t, buf)[0]
+	req := m["req"].(map[string]interface{})
+	if m["apikey"] != Redacted || req["Password"] != Redacted || req["auth"].(map[string]interface{})["token"] != Redacted || req["user"] != "admin" {
+		t.Fatalf("unexpected redaction %v", m)
+	}
+}
+
+func Test_GinTraceID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	buf := setupBuffer(t, Config{})
+
+	r := gin.New()
+	r.Use(GinMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		FromContext(c, "http").Info("from context")
+		For("http").InfoContext(c.Request.Context(), "info context")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Header().Get(HeaderRequestID) != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("unexpected request id %q", w.Header().Get(HeaderRequestID))
+	}
+	for _, m := range lines(t, buf) {
+		if m[TraceIDKey] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Fatalf("missing trace id %v", m)
+		}
+	}
+
+	// 没有header或包含非法字符时生成
+	for _, id := range []string{"", "a\nfake=log", "<script>", strings.Repeat("a", 129)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderRequestID, id)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get(HeaderRequestID); len(got) != 32 || got == id {
+			t.Fatalf("%q: unexpected request id %q", id, got)
+		}
+	}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderRequestID, "req-1.a_b")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(HeaderRequestID); got != "req-1.a_b" {
+		t.Fatalf("unexpected request id %q", got)
+	}
+}
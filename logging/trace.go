@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 请求ID的header, 没有时从W3C traceparent中读取trace-id, 都没有时生成
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceParent = "traceparent"
+)
+
+type traceIDKey struct{}
+
+// WithTraceID 在context中保存trace ID, 使用 *Context 方法输出的日志会带有trace_id
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceID 读取context中的trace ID, 支持*gin.Context
+func TraceID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if c, ok := ctx.(*gin.Context); ok {
+		if c.Request == nil {
+			return ""
+		}
+		ctx = c.Request.Context()
+	}
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// FromContext 返回带有trace_id的logger, pkg为For的包名
+func FromContext(ctx context.Context, pkg string) *slog.Logger {
+	return slog.New(&handler{pkg: pkg, traceID: TraceID(ctx)})
+}
+
+// GinMiddleware 为每个请求设置trace ID并写回X-Request-ID响应头
+// 之后的handler使用 logging.FromContext(c, pkg) 或 slog.InfoContext(c.Request.Context(), ...) 输出日志
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestTraceID(c.Request)
+		c.Header(HeaderRequestID, id)
+		c.Request = c.Request.WithContext(WithTraceID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// requestTraceID 客户端的ID会写入日志和响应头, 只接受字母、数字和 . _ -
+func requestTraceID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get(HeaderRequestID)); validRequestID(id) {
+		return id
+	}
+	// version-traceid-parentid-flags
+	if parts := strings.Split(r.Header.Get(HeaderTraceParent), "-"); len(parts) == 4 && len(parts[1]) == 32 {
+		if _, err := hex.DecodeString(parts[1]); err == nil {
+			return strings.ToLower(parts[1])
+		}
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,132 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Usage Run支持的命令
+const Usage = `usage: migrate <command> [--dry-run]
+  up              执行全部未执行的迁移
+  down [n]        回滚最近n个迁移, 默认1个
+  goto <version>  迁移到指定版本, 0为全部回滚
+  status          查看迁移状态
+  version         查看当前版本`
+
+// Run 命令行入口, 输出写入w, --dry-run只输出计划执行的步骤
+//
+//	err := m.Run(ctx, os.Stdout, os.Args[1:]...)
+func (m *Migrator) Run(ctx context.Context, w io.Writer, args ...string) error {
+	dryRun := false
+	rest := []string{}
+	for _, arg := range args {
+		if arg == "--dry-run" || arg == "-n" {
+			dryRun = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	if len(rest) == 0 {
+		return fmt.Errorf("%s", Usage)
+	}
+
+	// target在持有锁后按当时已执行的迁移计算
+	var target func(applied map[int64]record) int64
+	switch rest[0] {
+	case "status":
+		return m.printStatus(ctx, w)
+	case "version":
+		v, err := m.Version(ctx)
+		if err == nil {
+			fmt.Fprintln(w, v)
+		}
+		return err
+	case "up":
+		target = func(map[int64]record) int64 { return Latest }
+	case "down":
+		steps := 1
+		if len(rest) > 1 {
+			n, err := strconv.Atoi(rest[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid steps %q", rest[1])
+			}
+			steps = n
+		}
+		target = func(applied map[int64]record) int64 { return downTarget(applied, steps) }
+	case "goto":
+		if len(rest) < 2 {
+			return fmt.Errorf("%s", Usage)
+		}
+		t, err := strconv.ParseInt(rest[1], 10, 64)
+		if err != nil || t < 0 {
+			return fmt.Errorf("invalid version %q", rest[1])
+		}
+		target = func(map[int64]record) int64 { return t }
+	default:
+		return fmt.Errorf("unknown command %q\n%s", rest[0], Usage)
+	}
+
+	var steps []Step
+	var err error
+	if dryRun {
+		// 只读, 不需要加锁
+		var applied map[int64]record
+		if applied, err = m.applied(ctx); err != nil {
+			return err
+		}
+		if steps, err = m.plan(applied, target(applied)); err != nil {
+			return err
+		}
+		for _, step := range steps {
+			fmt.Fprintln(w, step)
+		}
+	} else {
+		// 输出实际执行的步骤
+		steps, err = m.migrate(ctx, target, func(step Step) { fmt.Fprintln(w, step) })
+		if err != nil {
+			return err
+		}
+	}
+	if len(steps) == 0 {
+		fmt.Fprintln(w, "no change")
+	}
+	return nil
+}
+
+// downTarget 回滚steps个迁移后的版本
+func downTarget(applied map[int64]record, steps int) int64 {
+	versions := appliedVersions(applied)
+	if steps >= len(versions) {
+		return 0
+	}
+	return versions[len(versions)-steps-1]
+}
+
+func (m *Migrator) printStatus(ctx context.Context, w io.Writer) error {
+	list, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range list {
+		state, at := "pending", ""
+		switch {
+		case s.Missing:
+			state = "missing"
+		case s.Dirty:
+			state = "dirty"
+		case s.Applied:
+			state = "applied"
+		}
+		if s.Applied {
+			at = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,476 @@
+// Package migrate 基于xorm的版本化数据库迁移
+//
+// 迁移按Version从小到大执行, 已执行的版本和checksum记录在schema_migrations表中,
+// 已执行的SQL被修改后会返回ErrChecksum, 避免不同环境的表结构不一致.
+// SQL迁移可以按数据库提供不同的版本, 见Migration.DialectSQL和LoadFS.
+// 每个迁移在单独的事务中执行, MySQL的DDL会隐式提交, 失败时需要手动处理.
+package migrate
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"xorm.io/xorm"
+
+	"github.com/lflxp/tools/logging"
+	"github.com/lflxp/tools/orm"
+)
+
+var logger = logging.For("orm/migrate")
+
+// Latest MigrateTo的目标版本, 执行全部迁移
+const Latest int64 = -1
+
+// 默认表名
+const (
+	DefaultTable     = "schema_migrations"
+	DefaultLockTable = "schema_migrations_lock"
+)
+
+var (
+	ErrChecksum       = errors.New("migrate: checksum mismatch")
+	ErrUnknownVersion = errors.New("migrate: unknown version")
+	ErrDuplicate      = errors.New("migrate: duplicate version")
+	ErrIrreversible   = errors.New("migrate: migration has no down")
+	ErrLocked         = errors.New("migrate: locked by another migrator")
+)
+
+// Direction 执行方向
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// Migration 一个版本的迁移, 使用Go函数(Up/Down)或SQL(UpSQL/DownSQL)
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(*xorm.Session) error
+	Down    func(*xorm.Session) error
+	UpSQL   string
+	DownSQL string
+	// DialectSQL 按数据库覆盖UpSQL/DownSQL, key为orm.Dialect的返回值
+	DialectSQL map[string]SQL
+}
+
+// SQL 一个数据库的up和down
+type SQL struct {
+	Up   string
+	Down string
+}
+
+// SQLFor 返回数据库使用的SQL, 没有覆盖时为UpSQL/DownSQL
+func (m *Migration) SQLFor(dialect string) SQL {
+	result := SQL{Up: m.UpSQL, Down: m.DownSQL}
+	if d, ok := m.DialectSQL[dialect]; ok {
+		if d.Up != "" {
+			result.Up = d.Up
+		}
+		if d.Down != "" {
+			result.Down = d.Down
+		}
+	}
+	return result
+}
+
+// Checksum 数据库实际执行的up SQL的sha256, Go函数无法计算内容, 使用名称
+func (m *Migration) Checksum(dialect string) string {
+	content := m.SQLFor(dialect).Up
+	if m.Up != nil {
+		content = "go:" + m.Name
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// reversible 当前数据库没有up SQL时只记录了版本, 回滚时也不需要执行SQL
+func (m *Migration) reversible(dialect string) bool {
+	if m.Up == nil && strings.TrimSpace(m.SQLFor(dialect).Up) == "" {
+		return true
+	}
+	return m.Down != nil || strings.TrimSpace(m.SQLFor(dialect).Down) != ""
+}
+
+// Status 迁移状态, Missing为已执行但代码中已不存在的版本
+type Status struct {
+	Version   int64     `json:"version"`
+	Name      string    `json:"name"`
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"appliedAt,omitempty"`
+	Dirty     bool      `json:"dirty,omitempty"`
+	Missing   bool      `json:"missing,omitempty"`
+}
+
+// Step 计划执行的一步
+type Step struct {
+	Migration *Migration
+	Direction Direction
+}
+
+func (s Step) String() string {
+	return fmt.Sprintf("%s %d %s", s.Direction, s.Migration.Version, s.Migration.Name)
+}
+
+// Migrator 迁移执行器, 多个进程同时执行时通过锁表互斥
+type Migrator struct {
+	Engine     *xorm.Engine
+	Migrations []*Migration
+	Table      string
+	LockTable  string
+	// Dialect 选择DialectSQL, 默认为orm.Dialect(engine)
+	Dialect string
+	// LockTimeout 等待锁的时间
+	LockTimeout time.Duration
+	// StaleLock 超过该时间的锁视为进程异常退出后残留, 会被清理
+	StaleLock time.Duration
+}
+
+// record schema_migrations中的一行
+type record struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// New 校验版本不重复并按版本排序
+func New(engine *xorm.Engine, migrations ...*Migration) (*Migrator, error) {
+	sorted := append([]*Migration{}, migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version <= 0 {
+			return nil, fmt.Errorf("%w: version must be positive: %d", ErrUnknownVersion, m.Version)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicate, m.Version)
+		}
+		// 只有其他数据库的SQL时, 当前数据库只记录版本
+		if m.Up == nil && strings.TrimSpace(m.UpSQL) == "" && len(m.DialectSQL) == 0 {
+			return nil, fmt.Errorf("migrate: %d %s has no up", m.Version, m.Name)
+		}
+	}
+	return &Migrator{
+		Engine:      engine,
+		Migrations:  sorted,
+		Table:       DefaultTable,
+		LockTable:   DefaultLockTable,
+		Dialect:     orm.Dialect(engine),
+		LockTimeout: 30 * time.Second,
+		StaleLock:   10 * time.Minute,
+	}, nil
+}
+
+// Up 执行全部未执行的迁移
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.MigrateTo(ctx, Latest)
+}
+
+// Down 回滚最近的steps个迁移
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return nil
+	}
+	_, err := m.migrate(ctx, func(applied map[int64]record) int64 { return downTarget(applied, steps) }, nil)
+	return err
+}
+
+// Version 当前已执行的最大版本, 没有执行过时为0
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	versions := appliedVersions(applied)
+	if len(versions) == 0 {
+		return 0, nil
+	}
+	return versions[len(versions)-1], nil
+}
+
+// Status 所有迁移的状态, 包括已执行但代码中不存在的版本
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []Status{}
+	known := map[int64]bool{}
+	for _, mig := range m.Migrations {
+		known[mig.Version] = true
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if r, ok := applied[mig.Version]; ok {
+			s.Applied, s.AppliedAt = true, r.AppliedAt
+			s.Dirty = r.Checksum != mig.Checksum(m.Dialect)
+		}
+		result = append(result, s)
+	}
+	for v, r := range applied {
+		if !known[v] {
+			result = append(result, Status{Version: v, Name: r.Name, Applied: true, AppliedAt: r.AppliedAt, Missing: true})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// Plan 计算迁移到target需要执行的步骤, 不修改数据库, 用于dry-run
+func (m *Migrator) Plan(ctx context.Context, target int64) ([]Step, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.plan(applied, target)
+}
+
+// MigrateTo 迁移到target版本, 比当前版本高时执行up, 低时执行down, 0为全部回滚
+func (m *Migrator) MigrateTo(ctx context.Context, target int64) error {
+	_, err := m.migrate(ctx, func(map[int64]record) int64 { return target }, nil)
+	return err
+}
+
+// migrate 持有锁后读取已执行的迁移, 再计算target和步骤, 避免与其他迁移进程的计划不一致
+// onStep在每一步执行前调用, 返回计划执行的全部步骤
+func (m *Migrator) migrate(ctx context.Context, target func(applied map[int64]record) int64, onStep func(Step)) ([]Step, error) {
+	if err := m.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := m.plan(applied, target(applied))
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		if onStep != nil {
+			onStep(step)
+		}
+		start := time.Now()
+		if err := m.run(ctx, step); err != nil {
+			return steps, fmt.Errorf("migrate %s: %w", step, err)
+		}
+		logger.Info("migrated", "version", step.Migration.Version, "name", step.Migration.Name, "direction", step.Direction, "duration", time.Since(start))
+	}
+	return steps, nil
+}
+
+func (m *Migrator) plan(applied map[int64]record, target int64) ([]Step, error) {
+	if err := m.validate(applied); err != nil {
+		return nil, err
+	}
+	if target == Latest {
+		target = 0
+		if n := len(m.Migrations); n > 0 {
+			target = m.Migrations[n-1].Version
+		}
+	} else if target != 0 && m.find(target) == nil {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, target)
+	}
+
+	steps := []Step{}
+	for i := len(m.Migrations) - 1; i >= 0; i-- {
+		mig := m.Migrations[i]
+		if _, ok := applied[mig.Version]; ok && mig.Version > target {
+			if !mig.reversible(m.Dialect) {
+				return nil, fmt.Errorf("%w: %d %s", ErrIrreversible, mig.Version, mig.Name)
+			}
+			steps = append(steps, Step{Migration: mig, Direction: DirectionDown})
+		}
+	}
+	for _, mig := range m.Migrations {
+		if _, ok := applied[mig.Version]; !ok && mig.Version <= target {
+			steps = append(steps, Step{Migration: mig, Direction: DirectionUp})
+		}
+	}
+	return steps, nil
+}
+
+// validate 已执行的迁移必须存在且内容没有被修改
+func (m *Migrator) validate(applied map[int64]record) error {
+	for v, r := range applied {
+		mig := m.find(v)
+		if mig == nil {
+			return fmt.Errorf("%w: %d %s is applied but not found", ErrUnknownVersion, v, r.Name)
+		}
+		if r.Checksum != mig.Checksum(m.Dialect) {
+			return fmt.Errorf("%w: %d %s", ErrChecksum, v, mig.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	i := sort.Search(len(m.Migrations), func(i int) bool { return m.Migrations[i].Version >= version })
+	if i < len(m.Migrations) && m.Migrations[i].Version == version {
+		return m.Migrations[i]
+	}
+	return nil
+}
+
+// run 在事务中执行迁移并更新schema_migrations
+func (m *Migrator) run(ctx context.Context, step Step) error {
+	session := m.Engine.NewSession().Context(ctx)
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return err
+	}
+
+	mig := step.Migration
+	query := mig.SQLFor(m.Dialect)
+	var err error
+	if step.Direction == DirectionUp {
+		if err = execute(session, mig.Up, m.Dialect, query.Up); err == nil {
+			_, err = session.Exec("INSERT INTO "+m.Table+" (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+				mig.Version, mig.Name, mig.Checksum(m.Dialect), time.Now().Unix())
+		}
+	} else {
+		if err = execute(session, mig.Down, m.Dialect, query.Down); err == nil {
+			_, err = session.Exec("DELETE FROM "+m.Table+" WHERE version = ?", mig.Version)
+		}
+	}
+	if err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+func execute(session *xorm.Session, fn func(*xorm.Session) error, dialect, query string) error {
+	if fn != nil {
+		return fn(session)
+	}
+	for _, stmt := range SplitDialectStatements(dialect, query) {
+		if _, err := session.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	for _, query := range []string{
+		"CREATE TABLE IF NOT EXISTS " + m.Table + " (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, checksum VARCHAR(64) NOT NULL, applied_at BIGINT NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS " + m.LockTable + " (id INTEGER NOT NULL PRIMARY KEY, owner VARCHAR(255) NOT NULL, locked_at BIGINT NOT NULL)",
+	} {
+		if _, err := m.Engine.Context(ctx).Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applied 读取已执行的版本, 表不存在时为空
+func (m *Migrator) applied(ctx context.Context) (map[int64]record, error) {
+	result := map[int64]record{}
+	exist, err := m.Engine.Context(ctx).IsTableExist(m.Table)
+	if err != nil || !exist {
+		return result, err
+	}
+	rows, err := m.Engine.Context(ctx).QueryString("SELECT version, name, checksum, applied_at FROM " + m.Table)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		v, err := strconv.ParseInt(row["version"], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		at, _ := strconv.ParseInt(row["applied_at"], 10, 64)
+		result[v] = record{Version: v, Name: row["name"], Checksum: row["checksum"], AppliedAt: time.Unix(at, 0)}
+	}
+	return result, nil
+}
+
+func appliedVersions(applied map[int64]record) []int64 {
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// lock 插入id=1的行作为锁, 主键冲突时等待, 超过StaleLock的锁会被清理
+// 持有锁期间每StaleLock/3刷新locked_at, 执行时间超过StaleLock的迁移不会被其他进程抢占
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	owner := fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(b))
+
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		_, err := m.Engine.Context(ctx).Exec("INSERT INTO "+m.LockTable+" (id, owner, locked_at) VALUES (1, ?, ?)", owner, time.Now().Unix())
+		if err == nil {
+			break
+		}
+		if !orm.IsUniqueViolation(err) {
+			return nil, fmt.Errorf("migrate: acquire lock: %w", err)
+		}
+		if m.StaleLock > 0 {
+			res, derr := m.Engine.Context(ctx).Exec("DELETE FROM "+m.LockTable+" WHERE id = 1 AND locked_at < ?", time.Now().Add(-m.StaleLock).Unix())
+			if derr == nil {
+				if n, _ := res.RowsAffected(); n > 0 {
+					logger.Warn("removed stale migration lock", "table", m.LockTable)
+					continue
+				}
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		interval := m.StaleLock / 3
+		if interval <= 0 {
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := m.Engine.Exec("UPDATE "+m.LockTable+" SET locked_at = ? WHERE id = 1 AND owner = ?", time.Now().Unix(), owner); err != nil {
+					logger.Error("refresh migration lock", "owner", owner, "error", err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		if _, err := m.Engine.Exec("DELETE FROM "+m.LockTable+" WHERE id = 1 AND owner = ?", owner); err != nil {
+			logger.Error("release migration lock", "owner", owner, "error", err)
+		}
+	}, nil
+}
@@ -0,0 +1,227 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"xorm.io/xorm"
+
+	"github.com/lflxp/tools/orm/sqlite"
+)
+
+var testFS = fstest.MapFS{
+	"migrations/0001_create_user.up.sql":   {Data: []byte("CREATE TABLE user (id INTEGER PRIMARY KEY, name TEXT);\n-- 默认用户; 注释中的分号\nINSERT INTO user (name) VALUES ('a;b');")},
+	"migrations/0001_create_user.down.sql": {Data: []byte("DROP TABLE user;")},
+	"migrations/0002_add_email.up.sql":     {Data: []byte("ALTER TABLE user ADD COLUMN email TEXT;")},
+	"migrations/0002_add_email.down.sql":   {Data: []byte("ALTER TABLE user DROP COLUMN email;")},
+	"migrations/README.md":                 {Data: []byte("ignored")},
+}
+
+type Role struct {
+	Id   int64
+	Name string
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, *xorm.Engine) {
+	engine, err := sqlite.NewEngine(sqlite.WithMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine.Close() })
+
+	list, err := LoadFS(testFS, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list = append(list, &Migration{
+		Version: 3,
+		Name:    "create_role",
+		Up:      func(s *xorm.Session) error { return s.Sync2(new(Role)) },
+		Down:    func(s *xorm.Session) error { return s.DropTable(new(Role)) },
+	})
+	m, err := New(engine, list...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, engine
+}
+
+func Test_MigrateUpDown(t *testing.T) {
+	ctx := context.Background()
+	m, engine := newTestMigrator(t)
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m.Version(ctx); v != 3 {
+		t.Fatalf("expected version 3, got %d", v)
+	}
+	rows, err := engine.QueryString("SELECT name, email FROM user")
+	if err != nil || len(rows) != 1 || rows[0]["name"] != "a;b" {
+		t.Fatalf("unexpected rows %v %v", rows, err)
+	}
+
+	if err := m.Down(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m.Version(ctx); v != 1 {
+		t.Fatalf("expected version 1, got %d", v)
+	}
+	if ok, _ := engine.IsTableExist(new(Role)); ok {
+		t.Fatal("role should be dropped")
+	}
+
+	if err := m.MigrateTo(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := engine.IsTableExist("user"); ok {
+		t.Fatal("user should be dropped")
+	}
+	if err := m.MigrateTo(ctx, 9); !errors.Is(err, ErrUnknownVersion) {
+		t.Fatalf("expected ErrUnknownVersion, got %v", err)
+	}
+}
+
+func Test_ChecksumAndStatus(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t)
+	if err := m.MigrateTo(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Migrations[0].UpSQL += "\nCREATE INDEX idx_name ON user (name);"
+	if err := m.Up(ctx); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("expected ErrChecksum, got %v", err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 3 || !status[0].Applied || !status[0].Dirty || status[1].Applied {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func Test_RunDryRun(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMigrator(t)
+
+	var out bytes.Buffer
+	if err := m.Run(ctx, &out, "goto", "2", "--dry-run"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "up 1 create_user\nup 2 add_email\n" {
+		t.Fatalf("unexpected plan %q", out.String())
+	}
+	if v, _ := m.Version(ctx); v != 0 {
+		t.Fatal("dry run should not migrate")
+	}
+
+	out.Reset()
+	if err := m.Run(ctx, &out, "up"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "up ") != 3 {
+		t.Fatalf("unexpected executed steps %q", out.String())
+	}
+	out.Reset()
+	if err := m.Run(ctx, &out, "down", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "down ") != 2 {
+		t.Fatalf("unexpected executed steps %q", out.String())
+	}
+	if v, _ := m.Version(ctx); v != 1 {
+		t.Fatalf("expected version 1, got %d", v)
+	}
+	out.Reset()
+	if err := m.Run(ctx, &out, "up"); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	if err := m.Run(ctx, &out, "status"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "applied") != 3 {
+		t.Fatalf("unexpected status %s", out.String())
+	}
+}
+
+func Test_Lock(t *testing.T) {
+	ctx := context.Background()
+	m, engine := newTestMigrator(t)
+	m.LockTimeout = 300 * time.Millisecond
+	if err := m.ensureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Exec("INSERT INTO "+m.LockTable+" (id, owner, locked_at) VALUES (1, 'other', ?)", time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(ctx); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	// 过期的锁被清理
+	m.StaleLock = time.Nanosecond
+	if _, err := engine.Exec("UPDATE "+m.LockTable+" SET locked_at = ?", time.Now().Add(-time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_LockHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	m, engine := newTestMigrator(t)
+	m.StaleLock = 3 * time.Second
+	if err := m.ensureTables(ctx); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Exec("UPDATE "+m.LockTable+" SET locked_at = ?", time.Now().Add(-time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	rows, err := engine.QueryString("SELECT locked_at FROM " + m.LockTable)
+	if err != nil || len(rows) != 1 || rows[0]["locked_at"] < strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10) {
+		t.Fatalf("lock not refreshed %v %v", rows, err)
+	}
+	unlock()
+
+	// 非主键冲突的错误直接返回, 不等待LockTimeout
+	if _, err := engine.Exec("DROP TABLE " + m.LockTable); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.lock(ctx); err == nil || errors.Is(err, ErrLocked) {
+		t.Fatalf("expected insert error, got %v", err)
+	}
+}
+
+func Test_SplitStatements(t *testing.T) {
+	stmts := SplitStatements("/* a; b */ SELECT 1; SELECT ';' -- x;y\n; ;\n-- only comment;")
+	if len(stmts) != 2 || stmts[0] != "/* a; b */ SELECT 1" || stmts[1] != "SELECT ';' -- x;y" {
+		t.Fatalf("unexpected statements %q", stmts)
+	}
+
+	// 反斜杠只在MySQL中转义
+	query := `INSERT INTO t VALUES ('C:\'); INSERT INTO t VALUES ('it\'s;')`
+	if stmts := SplitStatements(query); len(stmts) != 3 || stmts[0] != `INSERT INTO t VALUES ('C:\')` {
+		t.Fatalf("unexpected statements %q", stmts)
+	}
+	if stmts := SplitDialectStatements("mysql", query); len(stmts) != 1 {
+		t.Fatalf("unexpected mysql statements %q", stmts)
+	}
+	if stmts := SplitDialectStatements("mysql", `SELECT 'a\\'; SELECT 'b'`); len(stmts) != 2 {
+		t.Fatalf("unexpected mysql statements %q", stmts)
+	}
+}
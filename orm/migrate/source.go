@@ -0,0 +1,159 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lflxp/tools/orm"
+)
+
+// sqlFilePattern 如 0001_create_user.up.sql、0001_create_user.down.sql
+// 只用于某个数据库的文件带有数据库名: 0001_create_user.up.postgres.sql
+var sqlFilePattern = regexp.MustCompile(`^(\d+)_(.+?)\.(up|down)(?:\.(sqlite|mysql|postgres))?\.sql$`)
+
+// LoadFS 读取目录中的SQL迁移文件, 通常配合embed使用
+// 带数据库名的文件覆盖通用文件, 只有带数据库名的文件时其他数据库只记录版本, 不执行SQL
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//	list, err := migrate.LoadFS(migrations, "migrations")
+func LoadFS(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		match := sqlFilePattern.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("%w: %d (%s, %s)", ErrDuplicate, version, mig.Name, match[2])
+		}
+		if dialect := match[4]; dialect != "" {
+			if mig.DialectSQL == nil {
+				mig.DialectSQL = map[string]SQL{}
+			}
+			d := mig.DialectSQL[dialect]
+			if match[3] == "up" {
+				d.Up = string(data)
+			} else {
+				d.Down = string(data)
+			}
+			mig.DialectSQL[dialect] = d
+		} else if match[3] == "up" {
+			mig.UpSQL = string(data)
+		} else {
+			mig.DownSQL = string(data)
+		}
+	}
+
+	result := make([]*Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.UpSQL == "" && len(mig.DialectSQL) == 0 {
+			return nil, fmt.Errorf("migrate: %d %s has no up file", mig.Version, mig.Name)
+		}
+		result = append(result, mig)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// SplitStatements 按分号拆分SQL, 忽略引号和注释中的分号, 去掉空语句
+// 字符串中的反斜杠不是转义字符, MySQL使用SplitDialectStatements
+func SplitStatements(query string) []string {
+	return SplitDialectStatements("", query)
+}
+
+// SplitDialectStatements 同SplitStatements, dialect为mysql时字符串中的反斜杠为转义字符
+func SplitDialectStatements(dialect, query string) []string {
+	backslash := dialect == orm.DriverMySQL
+	var (
+		stmts []string
+		cur   strings.Builder
+	)
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" && !onlyComments(s) {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(query) {
+				if query[end] == c {
+					// 两个引号为转义
+					if end+1 < len(query) && query[end+1] == c {
+						end += 2
+						continue
+					}
+					break
+				}
+				if backslash && query[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			if end >= len(query) {
+				end = len(query) - 1
+			}
+			cur.WriteString(query[i : end+1])
+			i = end
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			cur.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i - 2
+			} else {
+				end += 2
+			}
+			cur.WriteString(query[i : i+2+end])
+			i += 1 + end
+		case c == ';':
+			flush()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
+
+func onlyComments(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,420 @@
+package orm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"xorm.io/builder"
+	"xorm.io/xorm"
+	"xorm.io/xorm/schemas"
+
+	"github.com/lflxp/tools/httpclient"
+	"github.com/lflxp/tools/logging"
+	"github.com/lflxp/tools/sdk/apicache/pkg/api"
+	"github.com/lflxp/tools/sdk/apicache/pkg/apiserver/query"
+)
+
+var logger = logging.For("orm")
+
+var ErrNotFound = errors.New("orm: record not found")
+
+// ErrConflict 有version列时, 记录已被其他请求修改
+var ErrConflict = errors.New("orm: record was modified")
+
+// ErrInvalidPagination List的分页参数无效
+var ErrInvalidPagination = errors.New("orm: invalid pagination")
+
+// Repository 单表的增删改查, List使用与apicache相同的query.Query和api.ListResult
+//
+//	type Host struct {
+//		Id      int64
+//		Name    string
+//		Created time.Time `xorm:"created"`
+//		Deleted time.Time `xorm:"deleted"`
+//	}
+//	repo, err := orm.NewRepository[Host](nil, nil)
+//	repo.Register(router.Group("/api/v1/hosts"))
+//
+// 过滤规则与apicache一致: name为包含匹配, names为逗号分隔的列表, 其他字段为相等匹配,
+// 不支持的字段被忽略. 有deleted字段时Delete为软删除, 查询自动排除已删除的记录.
+// 有version字段时Update为乐观锁, 请求体需带上读取时的version
+type Repository[T any] struct {
+	engine *xorm.Engine
+	table  *schemas.Table
+	// fields url参数 → 列名
+	fields map[query.Field]string
+	// input 请求体不能写入的列: 主键、created、updated、deleted和version(更新时保留)
+	input []*schemas.Column
+}
+
+// NewRepository engine为nil时使用NewOrmE(), aliases将url参数映射到列名, 如 {"name": "Name", "uid": "Uuid"}
+// 只有aliases中的参数可以过滤和排序, 避免通过密码等列逐个猜测取值;
+// 另外默认支持 creationTimestamp/createTime 对应created列, updateTime/lastUpdateTimestamp 对应updated列
+func NewRepository[T any](engine *xorm.Engine, aliases map[query.Field]string) (*Repository[T], error) {
+	if engine == nil {
+		var err error
+		if engine, err = NewOrmE(); err != nil {
+			return nil, err
+		}
+	}
+	table, err := engine.TableInfo(new(T))
+	if err != nil {
+		return nil, err
+	}
+	if len(table.PrimaryKeys) != 1 {
+		return nil, fmt.Errorf("orm: %s must have exactly one primary key", table.Name)
+	}
+
+	fields := map[query.Field]string{}
+	for col := range table.Created {
+		fields[query.FieldCreationTimeStamp] = col
+		fields[query.FieldCreateTime] = col
+	}
+	if table.Updated != "" {
+		fields[query.FieldUpdateTime] = table.Updated
+		fields[query.FieldLastUpdateTimestamp] = table.Updated
+	}
+	for field, col := range aliases {
+		if table.GetColumn(col) == nil {
+			return nil, fmt.Errorf("orm: %s has no column %s", table.Name, col)
+		}
+		fields[field] = col
+	}
+
+	var input []*schemas.Column
+	for _, col := range table.Columns() {
+		if col.IsPrimaryKey || col.IsCreated || col.IsUpdated || col.IsDeleted || col.IsVersion {
+			input = append(input, col)
+		}
+	}
+	return &Repository[T]{engine: engine, table: table, fields: fields, input: input}, nil
+}
+
+// Engine 返回使用的engine, 用于自定义查询
+func (r *Repository[T]) Engine() *xorm.Engine {
+	return r.engine
+}
+
+// Create 插入记录, 自增主键和created字段会回写到item
+func (r *Repository[T]) Create(ctx context.Context, item *T) error {
+	_, err := r.engine.Context(ctx).Insert(item)
+	return err
+}
+
+// Get 按主键查询, 不存在或已软删除时返回ErrNotFound
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	item := new(T)
+	ok, err := r.engine.Context(ctx).ID(id).Get(item)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return item, nil
+}
+
+// Update 按主键更新cols, cols为空时更新主键、created和deleted以外的所有字段(包括零值)
+// 有version字段时只更新version与item相同的记录, 不一致时返回ErrConflict
+func (r *Repository[T]) Update(ctx context.Context, id interface{}, item *T, cols ...string) error {
+	session := r.engine.Context(ctx).ID(id)
+	if len(cols) == 0 {
+		omit := []string{r.table.PrimaryKeys[0]}
+		for col := range r.table.Created {
+			omit = append(omit, col)
+		}
+		if r.table.Deleted != "" {
+			omit = append(omit, r.table.Deleted)
+		}
+		session = session.AllCols().Omit(omit...)
+	} else {
+		session = session.Cols(cols...)
+	}
+	n, err := session.Update(item)
+	if err != nil {
+		return err
+	}
+	// MySQL中值没有变化时影响行数为0, 需要再确认记录是否存在; version每次都会变化
+	if n == 0 {
+		if err := r.exist(ctx, id); err != nil {
+			return err
+		}
+		if r.table.Version != "" {
+			return ErrConflict
+		}
+	}
+	return nil
+}
+
+// Delete 有deleted字段时为软删除, 否则删除记录
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	n, err := r.engine.Context(ctx).ID(id).Delete(new(T))
+	if err == nil && n == 0 {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// HardDelete 删除记录, 包括已软删除的记录
+func (r *Repository[T]) HardDelete(ctx context.Context, id interface{}) error {
+	n, err := r.engine.Context(ctx).Unscoped().ID(id).Delete(new(T))
+	if err == nil && n == 0 {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// Restore 恢复软删除的记录, 没有deleted字段时返回错误
+func (r *Repository[T]) Restore(ctx context.Context, id interface{}) error {
+	if r.table.Deleted == "" {
+		return fmt.Errorf("orm: %s does not support soft delete", r.table.Name)
+	}
+	n, err := r.engine.Context(ctx).Unscoped().ID(id).Cols(r.table.Deleted).Nullable(r.table.Deleted).Update(new(T))
+	if err == nil && n == 0 {
+		err = ErrNotFound
+	}
+	return err
+}
+
+func (r *Repository[T]) exist(ctx context.Context, id interface{}) error {
+	ok, err := r.engine.Context(ctx).ID(id).Exist(new(T))
+	if err == nil && !ok {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// List 按query过滤、排序和分页, Data为 []*T
+func (r *Repository[T]) List(ctx context.Context, q *query.Query) (*api.ListResult, error) {
+	if q == nil {
+		q = query.New()
+	}
+	if q.Pagination == nil {
+		q.Pagination = query.NoPagination
+	}
+
+	session := r.engine.Context(ctx)
+	for field, value := range q.Filters {
+		cond, ok := r.filter(field, value)
+		if !ok {
+			logger.DebugContext(ctx, "ignore unsupported filter", "table", r.table.Name, "field", field)
+			continue
+		}
+		session = session.And(cond)
+	}
+
+	pk := r.engine.Quote(r.table.PrimaryKeys[0])
+	if col, ok := r.fields[q.SortBy]; ok {
+		col = r.engine.Quote(col)
+		if q.Ascending {
+			session = session.Asc(col)
+		} else {
+			session = session.Desc(col)
+		}
+	}
+	// 排序字段相同时按主键排序, 保证分页稳定
+	if q.Ascending {
+		session = session.Asc(pk)
+	} else {
+		session = session.Desc(pk)
+	}
+	if q.Pagination.Limit != query.NoPagination.Limit {
+		if q.Pagination.Limit < 0 || q.Pagination.Offset < 0 {
+			return nil, fmt.Errorf("%w: limit=%d offset=%d", ErrInvalidPagination, q.Pagination.Limit, q.Pagination.Offset)
+		}
+		session = session.Limit(q.Pagination.Limit, q.Pagination.Offset)
+	}
+
+	items := []T{}
+	total, err := session.FindAndCount(&items)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]interface{}, len(items))
+	for i := range items {
+		data[i] = &items[i]
+	}
+	return &api.ListResult{
+		Data: data,
+		Pagination: api.Pagination{
+			Limit:  q.Pagination.Limit,
+			Total:  int(total),
+			Offset: q.Pagination.Offset,
+			Page:   q.Pagination.Page,
+		},
+	}, nil
+}
+
+// filter name为包含匹配, names为列表匹配, 其他字段相等匹配
+func (r *Repository[T]) filter(field query.Field, value query.Value) (builder.Cond, bool) {
+	switch field {
+	case query.FieldNames:
+		col, ok := r.fields[query.FieldName]
+		if !ok {
+			return nil, false
+		}
+		names := []interface{}{}
+		for _, name := range strings.Split(string(value), ",") {
+			names = append(names, name)
+		}
+		return builder.In(r.engine.Quote(col), names...), true
+	case query.FieldName:
+		col, ok := r.fields[field]
+		if !ok {
+			return nil, false
+		}
+		return builder.Expr(r.engine.Quote(col)+" LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(string(value))+"%"), true
+	default:
+		col, ok := r.fields[field]
+		if !ok {
+			return nil, false
+		}
+		return builder.Eq{r.engine.Quote(col): string(value)}, true
+	}
+}
+
+// likeEscaper 转义LIKE中的通配符, 使用各数据库通用的 ! 作为转义字符
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// parseID 整数主键转换为int64
+func (r *Repository[T]) parseID(s string) (interface{}, error) {
+	col := r.table.GetColumn(r.table.PrimaryKeys[0])
+	if f, ok := r.table.Type.FieldByName(col.FieldName); ok {
+		switch f.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return strconv.ParseInt(s, 10, 64)
+		}
+	}
+	return s, nil
+}
+
+// Register 注册 GET / 、GET /:id 、POST / 、PUT /:id 、DELETE /:id
+func (r *Repository[T]) Register(group *gin.RouterGroup) {
+	group.GET("", r.ListHandler)
+	group.GET("/:id", r.GetHandler)
+	group.POST("", r.CreateHandler)
+	group.PUT("/:id", r.UpdateHandler)
+	group.DELETE("/:id", r.DeleteHandler)
+}
+
+// ListHandler 参数与apicache一致: page、limit、sortBy、ascending和字段过滤
+func (r *Repository[T]) ListHandler(c *gin.Context) {
+	result, err := r.List(c.Request.Context(), query.ParseQueryParameter(c))
+	if errors.Is(err, ErrInvalidPagination) {
+		httpclient.SendErrorMessage(c, http.StatusBadRequest, httpclient.FailedParamsError, err.Error())
+		return
+	}
+	if err != nil {
+		r.sendError(c, err)
+		return
+	}
+	httpclient.SendSuccessMessage(c, http.StatusOK, result)
+}
+
+func (r *Repository[T]) GetHandler(c *gin.Context) {
+	id, err := r.parseID(c.Param("id"))
+	if err != nil {
+		httpclient.SendErrorMessage(c, http.StatusBadRequest, httpclient.FailedParamsError, err.Error())
+		return
+	}
+	item, err := r.Get(c.Request.Context(), id)
+	if err != nil {
+		r.sendError(c, err)
+		return
+	}
+	httpclient.SendSuccessMessage(c, http.StatusOK, item)
+}
+
+// CreateHandler 非自增主键由请求体指定, 其他受保护的列被忽略
+func (r *Repository[T]) CreateHandler(c *gin.Context) {
+	item := new(T)
+	if err := c.ShouldBindJSON(item); err != nil {
+		httpclient.SendErrorMessage(c, http.StatusBadRequest, httpclient.JsonError, err.Error())
+		return
+	}
+	r.resetInput(item, true)
+	if err := r.Create(c.Request.Context(), item); err != nil {
+		r.sendError(c, err)
+		return
+	}
+	httpclient.SendSuccessMessage(c, http.StatusCreated, item)
+}
+
+// UpdateHandler 整体更新, 主键以路径中的id为准
+func (r *Repository[T]) UpdateHandler(c *gin.Context) {
+	id, err := r.parseID(c.Param("id"))
+	if err != nil {
+		httpclient.SendErrorMessage(c, http.StatusBadRequest, httpclient.FailedParamsError, err.Error())
+		return
+	}
+	item := new(T)
+	if err := c.ShouldBindJSON(item); err != nil {
+		httpclient.SendErrorMessage(c, http.StatusBadRequest, httpclient.JsonError, err.Error())
+		return
+	}
+	r.resetInput(item, false)
+	if err := r.Update(c.Request.Context(), id, item); err != nil {
+		r.sendError(c, err)
+		return
+	}
+	item, err = r.Get(c.Request.Context(), id)
+	if err != nil {
+		r.sendError(c, err)
+		return
+	}
+	httpclient.SendSuccessMessage(c, http.StatusOK, item)
+}
+
+func (r *Repository[T]) DeleteHandler(c *gin.Context) {
+	id, err := r.parseID(c.Param("id"))
+	if err != nil {
+		httpclient.SendErrorMessage(c, http.StatusBadRequest, httpclient.FailedParamsError, err.Error())
+		return
+	}
+	if err := r.Delete(c.Request.Context(), id); err != nil {
+		r.sendError(c, err)
+		return
+	}
+	httpclient.SendSuccessMessage(c, http.StatusOK, nil)
+}
+
+func (r *Repository[T]) sendError(c *gin.Context, err error) {
+	if errors.Is(err, ErrNotFound) {
+		httpclient.SendErrorMessage(c, http.StatusNotFound, httpclient.ResourceNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, ErrConflict) {
+		httpclient.SendErrorMessage(c, http.StatusConflict, httpclient.ResourceConflict, err.Error())
+		return
+	}
+	// 唯一索引冲突的原始错误包含表结构和取值, 只返回错误码文案
+	if IsUniqueViolation(err) {
+		httpclient.SendError(c, httpclient.WithCode(err, httpclient.ResourceConflict))
+		return
+	}
+	httpclient.SendError(c, httpclient.WithCode(err, httpclient.SystemError))
+}
+
+// resetInput 清空请求体中受保护的列, create为true时保留非自增主键, 更新时保留version用于乐观锁
+func (r *Repository[T]) resetInput(item *T, create bool) {
+	for _, col := range r.input {
+		if create && col.IsPrimaryKey && !col.IsAutoIncrement {
+			continue
+		}
+		if !create && col.IsVersion {
+			continue
+		}
+		v, err := col.ValueOf(item)
+		if err != nil || !v.CanSet() {
+			continue
+		}
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
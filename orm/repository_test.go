@@ -0,0 +1,324 @@
+package orm_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/orm"
+	"github.com/lflxp/tools/sdk/apicache/pkg/api"
+	"github.com/lflxp/tools/sdk/apicache/pkg/apiserver/query"
+)
+
+type Cluster struct {
+	Id      int64     `json:"id"`
+	Name    string    `json:"name"`
+	Region  string    `json:"region"`
+	Created time.Time `xorm:"created" json:"created"`
+	Deleted time.Time `xorm:"deleted" json:"-"`
+}
+
+func newClusterRepo(t *testing.T) *orm.Repository[Cluster] {
+	engine, err := orm.Open("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	if err := engine.Sync2(new(Cluster)); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := orm.NewRepository[Cluster](engine, map[query.Field]string{"name": "Name", "region": "Region", "zone": "Region"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, c := range []Cluster{{Name: "prod-a", Region: "east"}, {Name: "prod-b", Region: "west"}, {Name: "dev", Region: "east"}, {Name: "dev_100%", Region: "south"}} {
+		c := c
+		if err := repo.Create(ctx, &c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func names(result *api.ListResult) string {
+	list := []string{}
+	for _, item := range result.Data {
+		list = append(list, item.(*Cluster).Name)
+	}
+	return strings.Join(list, ",")
+}
+
+func Test_RepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := newClusterRepo(t)
+
+	c, err := repo.Get(ctx, 1)
+	if err != nil || c.Name != "prod-a" || c.Created.IsZero() {
+		t.Fatalf("get %+v %v", c, err)
+	}
+
+	// 整体更新不修改主键、created和deleted
+	if err := repo.Update(ctx, 1, &Cluster{Name: "prod-a", Region: "north"}); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := repo.Get(ctx, 1)
+	if updated.Id != 1 || updated.Region != "north" || !updated.Created.Equal(c.Created) {
+		t.Fatalf("update %+v", updated)
+	}
+	if err := repo.Update(ctx, 9, &Cluster{Name: "x"}); !errors.Is(err, orm.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := repo.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, 1); !errors.Is(err, orm.ErrNotFound) {
+		t.Fatalf("expected soft deleted, got %v", err)
+	}
+	if n, _ := repo.Engine().Unscoped().Count(new(Cluster)); n != 4 {
+		t.Fatalf("soft delete should keep the row, count %d", n)
+	}
+	if err := repo.Restore(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.HardDelete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := repo.Engine().Unscoped().Count(new(Cluster)); n != 3 {
+		t.Fatalf("hard delete should remove the row, count %d", n)
+	}
+}
+
+func Test_RepositoryList(t *testing.T) {
+	ctx := context.Background()
+	repo := newClusterRepo(t)
+
+	q := query.New()
+	q.SortBy = query.FieldName
+	q.Ascending = true
+	q.Filters[query.FieldName] = "prod"
+	q.Filters["unknown"] = "ignored"
+	// 没有别名的列不能过滤
+	q.Filters["id"] = "3"
+	result, err := repo.List(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names(result) != "prod-a,prod-b" || result.Pagination.Total != 2 {
+		t.Fatalf("unexpected list %s %+v", names(result), result.Pagination)
+	}
+
+	q = query.New()
+	q.Filters["zone"] = "east"
+	q.Filters[query.FieldNames] = "dev,prod-a,prod-b"
+	result, _ = repo.List(ctx, q)
+	if names(result) != "dev,prod-a" {
+		t.Fatalf("unexpected list %s", names(result))
+	}
+
+	// % 和 _ 按字面匹配
+	for value, expected := range map[string]string{"_1": "dev_100%", "0%": "dev_100%", "v%1": "", "d_v": ""} {
+		q = query.New()
+		q.Filters[query.FieldName] = query.Value(value)
+		if result, _ = repo.List(ctx, q); names(result) != expected {
+			t.Fatalf("like %q: unexpected list %s", value, names(result))
+		}
+	}
+
+	// 软删除的记录不出现在列表中
+	repo.Delete(ctx, 3)
+	repo.Delete(ctx, 4)
+	q = query.New()
+	q.SortBy = query.FieldCreationTimeStamp
+	q.Pagination = &query.Pagination{Limit: 1, Offset: 1, Page: 2}
+	result, _ = repo.List(ctx, q)
+	if names(result) != "prod-a" || result.Pagination.Total != 2 || result.Pagination.Page != 2 {
+		t.Fatalf("unexpected page %s %+v", names(result), result.Pagination)
+	}
+}
+
+func Test_RepositoryHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newClusterRepo(t)
+	r := gin.New()
+	repo.Register(r.Group("/clusters"))
+
+	do := func(method, url, body string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		m := map[string]interface{}{}
+		json.Unmarshal(w.Body.Bytes(), &m)
+		return w.Code, m
+	}
+
+	code, m := do(http.MethodGet, "/clusters?page=1&limit=2&sortBy=name&ascending=true&region=east", "")
+	data, _ := m["data"].(map[string]interface{})
+	if code != http.StatusOK || data == nil || len(data["data"].([]interface{})) != 2 {
+		t.Fatalf("list %d %v", code, m)
+	}
+	if total := data["pagination"].(map[string]interface{})["total"]; total != float64(2) {
+		t.Fatalf("unexpected total %v", total)
+	}
+
+	// 请求体中的自增主键被忽略
+	if code, m := do(http.MethodPost, "/clusters", `{"id":1,"name":"test","region":"south"}`); code != http.StatusCreated || m["data"].(map[string]interface{})["id"] != float64(5) {
+		t.Fatalf("create %d %v", code, m)
+	}
+	if code, m := do(http.MethodPut, "/clusters/5", `{"id":1,"name":"test","region":"east"}`); code != http.StatusOK || m["data"].(map[string]interface{})["region"] != "east" {
+		t.Fatalf("update %d %v", code, m)
+	}
+	if c, err := repo.Get(context.Background(), 1); err != nil || c.Name != "prod-a" {
+		t.Fatalf("update changed another row %+v %v", c, err)
+	}
+	if code, _ := do(http.MethodDelete, "/clusters/5", ""); code != http.StatusOK {
+		t.Fatalf("delete %d", code)
+	}
+	if code, _ := do(http.MethodGet, "/clusters/5", ""); code != http.StatusNotFound {
+		t.Fatalf("get deleted %d", code)
+	}
+	if code, _ := do(http.MethodGet, "/clusters/abc", ""); code != http.StatusBadRequest {
+		t.Fatalf("bad id %d", code)
+	}
+	if code, _ := do(http.MethodGet, "/clusters?limit=-2", ""); code != http.StatusBadRequest {
+		t.Fatalf("bad pagination %d", code)
+	}
+}
+
+type Account struct {
+	Name     string    `xorm:"pk" json:"name"`
+	Password string    `json:"password"`
+	Deleted  time.Time `xorm:"deleted" json:"deleted"`
+}
+
+func Test_RepositoryProtectedColumns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := orm.Open("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	if err := engine.Sync2(new(Account)); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := orm.NewRepository[Account](engine, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	repo.Register(r.Group("/accounts"))
+	do := func(method, url, body string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return w.Code
+	}
+
+	// 非自增主键由请求体指定, deleted被忽略
+	if code := do(http.MethodPost, "/accounts", `{"name":"admin","password":"secret","deleted":"2020-01-01T00:00:00Z"}`); code != http.StatusCreated {
+		t.Fatalf("create %d", code)
+	}
+	// 主键重复返回409, 不返回数据库错误
+	if code := do(http.MethodPost, "/accounts", `{"name":"admin","password":"other"}`); code != http.StatusConflict {
+		t.Fatalf("duplicate create %d", code)
+	}
+	// 更新不能修改主键
+	if code := do(http.MethodPut, "/accounts/admin", `{"name":"root","password":"changed"}`); code != http.StatusOK {
+		t.Fatalf("update %d", code)
+	}
+	a, err := repo.Get(context.Background(), "admin")
+	if err != nil || a.Password != "changed" {
+		t.Fatalf("get %+v %v", a, err)
+	}
+
+	// 没有别名时不能按密码过滤
+	q := query.New()
+	q.Filters["password"] = "wrong"
+	if result, _ := repo.List(context.Background(), q); len(result.Data) != 1 {
+		t.Fatalf("password filter should be ignored, got %d", len(result.Data))
+	}
+}
+
+type Document struct {
+	Id      int64  `json:"id"`
+	Title   string `json:"title"`
+	Version int    `xorm:"version" json:"version"`
+}
+
+func Test_RepositoryVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := orm.Open("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	if err := engine.Sync2(new(Document)); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := orm.NewRepository[Document](engine, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	repo.Register(r.Group("/documents"))
+	do := func(method, url, body string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		m := map[string]interface{}{}
+		json.Unmarshal(w.Body.Bytes(), &m)
+		return w.Code, m
+	}
+
+	// 创建时忽略请求体中的version
+	if code, m := do(http.MethodPost, "/documents", `{"title":"a","version":7}`); code != http.StatusCreated || m["data"].(map[string]interface{})["version"] != float64(1) {
+		t.Fatalf("create %d %v", code, m)
+	}
+	if code, m := do(http.MethodPut, "/documents/1", `{"title":"b","version":1}`); code != http.StatusOK || m["data"].(map[string]interface{})["version"] != float64(2) {
+		t.Fatalf("update %d %v", code, m)
+	}
+	// 过期或缺少version时返回409, 记录不变
+	for _, body := range []string{`{"title":"c","version":1}`, `{"title":"c"}`} {
+		if code, _ := do(http.MethodPut, "/documents/1", body); code != http.StatusConflict {
+			t.Fatalf("stale update %s: %d", body, code)
+		}
+	}
+	if d, err := repo.Get(context.Background(), 1); err != nil || d.Title != "b" || d.Version != 2 {
+		t.Fatalf("get %+v %v", d, err)
+	}
+	if err := repo.Update(context.Background(), 1, &Document{Title: "d", Version: 1}); !errors.Is(err, orm.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+	if err := repo.Update(context.Background(), 9, &Document{Title: "d", Version: 1}); !errors.Is(err, orm.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func Test_RepositoryListError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine, err := orm.Open("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine.Close() })
+	// 表不存在, 数据库错误不返回给客户端
+	repo, err := orm.NewRepository[Account](engine, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	repo.Register(r.Group("/accounts"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/accounts", nil))
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "no such table") {
+		t.Fatalf("list %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,174 @@
+package sqlite
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	"k8s.io/client-go/util/homedir"
+	"xorm.io/xorm"
+	"xorm.io/xorm/names"
+
+	"github.com/lflxp/tools/logging"
+	"github.com/lflxp/tools/orm/sqllog"
+)
+
+// Memory 内存数据库, 每个engine独立
+const Memory = ":memory:"
+
+// Options NewEngine的配置, 默认值见DefaultOptions
+type Options struct {
+	// Path 数据库文件或Memory
+	Path string
+	// WAL 使用WAL日志模式, 读写可以并发
+	WAL bool
+	// BusyTimeout 数据库被锁定时的等待时间
+	BusyTimeout time.Duration
+	// ForeignKeys 启用外键约束
+	ForeignKeys bool
+	// 连接池, Memory时固定为1个连接
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// 表名和字段名的映射
+	TableMapper  names.Mapper
+	ColumnMapper names.Mapper
+	// ShowSQL 以debug级别输出SQL
+	ShowSQL bool
+	// Logger SQL日志, 默认 logging.For("orm/sqlite")
+	Logger *slog.Logger
+}
+
+// Option 修改Options
+type Option func(*Options)
+
+// DefaultOptions 与NewOrm一致: ~/.showme.db, 300个连接, SnakeMapper/SameMapper
+func DefaultOptions() Options {
+	return Options{
+		Path:         filepath.Join(homedir.HomeDir(), ".showme.db"),
+		BusyTimeout:  5 * time.Second,
+		MaxOpenConns: 300,
+		MaxIdleConns: 300,
+		TableMapper:  names.SnakeMapper{},
+		ColumnMapper: names.SameMapper{},
+	}
+}
+
+// WithPath 数据库文件路径, 使用Memory时为内存数据库
+func WithPath(path string) Option {
+	return func(o *Options) { o.Path = path }
+}
+
+// WithMemory 内存数据库, 用于测试
+func WithMemory() Option {
+	return WithPath(Memory)
+}
+
+// WithWAL 使用WAL日志模式
+func WithWAL(wal bool) Option {
+	return func(o *Options) { o.WAL = wal }
+}
+
+// WithBusyTimeout 数据库被锁定时的等待时间, 0为立即返回SQLITE_BUSY
+func WithBusyTimeout(d time.Duration) Option {
+	return func(o *Options) { o.BusyTimeout = d }
+}
+
+// WithForeignKeys 启用外键约束
+func WithForeignKeys(enable bool) Option {
+	return func(o *Options) { o.ForeignKeys = enable }
+}
+
+// WithPool 连接池大小
+func WithPool(maxOpen, maxIdle int, maxLifetime time.Duration) Option {
+	return func(o *Options) {
+		o.MaxOpenConns, o.MaxIdleConns, o.ConnMaxLifetime = maxOpen, maxIdle, maxLifetime
+	}
+}
+
+// WithMapper 表名和字段名映射, 为nil时不修改
+func WithMapper(table, column names.Mapper) Option {
+	return func(o *Options) {
+		if table != nil {
+			o.TableMapper = table
+		}
+		if column != nil {
+			o.ColumnMapper = column
+		}
+	}
+}
+
+// WithSQLLog 通过slog输出SQL, logger为nil时使用 logging.For("orm/sqlite")
+func WithSQLLog(logger *slog.Logger) Option {
+	return func(o *Options) {
+		o.ShowSQL = true
+		o.Logger = logger
+	}
+}
+
+// DSN 生成modernc.org/sqlite的连接串, pragma在每个连接建立时执行
+func (o Options) DSN() string {
+	q := url.Values{}
+	if o.BusyTimeout > 0 {
+		q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", o.BusyTimeout.Milliseconds()))
+	}
+	if o.WAL && o.Path != Memory {
+		q.Add("_pragma", "journal_mode(WAL)")
+	}
+	if o.ForeignKeys {
+		q.Add("_pragma", "foreign_keys(1)")
+	}
+	if len(q) == 0 {
+		return o.Path
+	}
+	return o.Path + "?" + q.Encode()
+}
+
+// NewEngine 按选项创建独立的engine并检查连接, 调用方负责Close
+func NewEngine(opts ...Option) (*xorm.Engine, error) {
+	engine, err := newEngine(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := engine.Ping(); err != nil {
+		engine.Close()
+		return nil, err
+	}
+	return engine, nil
+}
+
+// newEngine 同NewEngine, 不检查连接, 打开文件的错误在第一次查询时返回
+func newEngine(opts ...Option) (*xorm.Engine, error) {
+	o := DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Path == "" {
+		return nil, fmt.Errorf("sqlite: empty path")
+	}
+
+	engine, err := xorm.NewEngine("sqlite", o.DSN())
+	if err != nil {
+		return nil, err
+	}
+
+	// 内存数据库每个连接都是独立的库, 只能使用一个连接且不能被回收
+	if o.Path == Memory {
+		o.MaxOpenConns, o.MaxIdleConns, o.ConnMaxLifetime = 1, 1, 0
+	}
+	engine.SetMaxOpenConns(o.MaxOpenConns)
+	engine.SetMaxIdleConns(o.MaxIdleConns)
+	engine.SetConnMaxLifetime(o.ConnMaxLifetime)
+	engine.SetTableMapper(o.TableMapper)
+	engine.SetColumnMapper(o.ColumnMapper)
+
+	logger := o.Logger
+	if logger == nil {
+		logger = logging.For("orm/sqlite")
+	}
+	engine.SetLogger(sqllog.New(logger, o.ShowSQL))
+	engine.ShowSQL(o.ShowSQL)
+	return engine, nil
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"xorm.io/xorm/names"
+)
+
+type User struct {
+	Id   int64
+	Name string
+}
+
+func Test_NewEngineMemory(t *testing.T) {
+	a, err := NewEngine(WithMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := NewEngine(WithMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if err := a.Sync2(new(User)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Insert(&User{Name: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	// 内存库在多次查询间保持数据, engine之间互不影响
+	if n, err := a.Count(new(User)); err != nil || n != 1 {
+		t.Fatalf("count %d %v", n, err)
+	}
+	if ok, _ := b.IsTableExist(new(User)); ok {
+		t.Fatal("memory engines should be independent")
+	}
+}
+
+func Test_NewEnginePragmas(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	engine, err := NewEngine(
+		WithPath(filepath.Join(t.TempDir(), "test.db")),
+		WithWAL(true),
+		WithForeignKeys(true),
+		WithBusyTimeout(2*time.Second),
+		WithPool(4, 2, time.Minute),
+		WithMapper(names.GonicMapper{}, names.GonicMapper{}),
+		WithSQLLog(logger),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	pragma := func(name string) string {
+		rows, err := engine.QueryString("PRAGMA " + name)
+		if err != nil || len(rows) == 0 {
+			t.Fatalf("pragma %s: %v", name, err)
+		}
+		for _, v := range rows[0] {
+			return v
+		}
+		return ""
+	}
+	if v := pragma("journal_mode"); v != "wal" {
+		t.Fatalf("journal_mode %s", v)
+	}
+	if v := pragma("foreign_keys"); v != "1" {
+		t.Fatalf("foreign_keys %s", v)
+	}
+	if v := pragma("busy_timeout"); v != "2000" {
+		t.Fatalf("busy_timeout %s", v)
+	}
+
+	if err := engine.Sync2(new(User)); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := engine.IsTableExist("user"); !ok {
+		t.Fatal("table mapper not applied")
+	}
+	if !strings.Contains(buf.String(), "level=DEBUG msg=sql") {
+		t.Fatalf("sql not logged: %s", buf.String())
+	}
+}
+
+func Test_NewOrmDBName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "showme.db")
+	SetDBName(path)
+	engine := NewOrm()
+	if err := engine.Sync2(new(User)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(engine.DataSourceName(), path+"?") {
+		t.Fatalf("unexpected dsn %s", engine.DataSourceName())
+	}
+}
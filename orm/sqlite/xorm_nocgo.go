@@ -6,44 +6,44 @@ import (
 
 	"k8s.io/client-go/util/homedir"
 	_ "modernc.org/sqlite"
-	"xorm.io/core"
 	"xorm.io/xorm"
 )
 
 var (
-	ormOnce sync.Once
-	orm     *xorm.Engine
-	dbname  string
+	ormOnce       sync.Once
+	defaultEngine *xorm.Engine
+	dbnameLock    sync.Mutex
+	dbname        string
 )
 
-func NewOrm() *xorm.Engine {
-	if dbname == "" {
-		dbname = ".showme.db"
-	}
+// SetDBName 修改NewOrm使用的数据库文件, 相对路径以home目录为准, 需要在第一次调用NewOrm之前设置
+// 需要多个数据库时使用NewEngine
+func SetDBName(name string) {
+	dbnameLock.Lock()
+	defer dbnameLock.Unlock()
+	dbname = name
+}
 
+// NewOrm 进程内共享的默认engine(~/.showme.db), 需要其他配置或独立engine时使用NewEngine
+// 不检查连接, 无法打开数据库文件时在第一次查询返回错误
+func NewOrm() *xorm.Engine {
 	ormOnce.Do(func() {
-		home := homedir.HomeDir()
+		dbnameLock.Lock()
+		path := dbname
+		dbnameLock.Unlock()
+		if path == "" {
+			path = ".showme.db"
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(homedir.HomeDir(), path)
+		}
+
 		var err error
-		orm, err = xorm.NewEngine("sqlite", filepath.Join(home, dbname))
+		defaultEngine, err = newEngine(WithPath(path))
 		if err != nil {
 			panic(err)
 		}
-
-		orm.ShowSQL(false)
-		// orm.Logger().SetLevel(log.LogLevel(core.LOG_WARNING))
-		orm.SetMaxIdleConns(300)
-		orm.SetMaxOpenConns(300)
-		orm.SetMapper(core.SnakeMapper{})
-		// logger.Errorf("viper------------- is %s ",viper.GetString("snakemapper"))
-		// tbMapper := core.NewPrefixMapper(core.SnakeMapper{}, viper.GetString("snakemapper"))
-		// Engine.SetTableMapper(tbMapper)
-		orm.SetColumnMapper(core.SameMapper{})
-
-		// err = orm.Sync2(new(History))
-		// if err != nil {
-		// 	logger.Fatal(err.Error())
-		// }
 	})
 
-	return orm
+	return defaultEngine
 }
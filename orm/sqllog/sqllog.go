@@ -0,0 +1,82 @@
+// Package sqllog 将xorm的日志输出到slog, 各数据库的engine共用
+package sqllog
+
+import (
+	"fmt"
+	"log/slog"
+
+	xlog "xorm.io/xorm/log"
+)
+
+// Logger 实现xorm的ContextLogger, ShowSQL为true时SQL以debug级别输出
+// 执行出错的SQL以warn级别输出, 带有耗时和参数
+type Logger struct {
+	logger  *slog.Logger
+	level   xlog.LogLevel
+	showSQL bool
+}
+
+var _ xlog.ContextLogger = (*Logger)(nil)
+
+// New logger为nil时使用slog.Default()
+func New(logger *slog.Logger, showSQL bool) *Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Logger{logger: logger, level: xlog.LOG_INFO, showSQL: showSQL}
+}
+
+// BeforeSQL 只在AfterSQL中输出
+func (l *Logger) BeforeSQL(ctx xlog.LogContext) {}
+
+// AfterSQL 输出SQL、参数和耗时
+func (l *Logger) AfterSQL(ctx xlog.LogContext) {
+	attrs := []any{"sql", ctx.SQL, "args", ctx.Args, "duration", ctx.ExecuteTime}
+	if session, ok := ctx.Ctx.Value(xlog.SessionIDKey).(string); ok {
+		attrs = append(attrs, "session", session)
+	}
+	if ctx.Err != nil {
+		l.logger.WarnContext(ctx.Ctx, "sql failed", append(attrs, "error", ctx.Err)...)
+		return
+	}
+	l.logger.DebugContext(ctx.Ctx, "sql", attrs...)
+}
+
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	if l.level <= xlog.LOG_DEBUG {
+		l.logger.Debug(fmt.Sprintf(format, v...))
+	}
+}
+
+func (l *Logger) Infof(format string, v ...interface{}) {
+	if l.level <= xlog.LOG_INFO {
+		l.logger.Info(fmt.Sprintf(format, v...))
+	}
+}
+
+func (l *Logger) Warnf(format string, v ...interface{}) {
+	if l.level <= xlog.LOG_WARNING {
+		l.logger.Warn(fmt.Sprintf(format, v...))
+	}
+}
+
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	if l.level <= xlog.LOG_ERR {
+		l.logger.Error(fmt.Sprintf(format, v...))
+	}
+}
+
+func (l *Logger) Level() xlog.LogLevel { return l.level }
+
+func (l *Logger) SetLevel(level xlog.LogLevel) { l.level = level }
+
+// ShowSQL 与xorm一致, 不传参数时打开
+func (l *Logger) ShowSQL(show ...bool) {
+	if len(show) == 0 {
+		l.showSQL = true
+		return
+	}
+	l.showSQL = show[0]
+}
+
+func (l *Logger) IsShowSQL() bool { return l.showSQL }
@@ -0,0 +1,606 @@
+package rsa
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/lflxp/tools/sdk/clientgo"
+	"github.com/lflxp/tools/utils"
+)
+
+const (
+	PEMTypeCertificateRequest = "CERTIFICATE REQUEST"
+	PEMTypeCRL                = "X509 CRL"
+)
+
+// 默认有效期
+const (
+	DefaultCAValidity   = 10 * 365 * 24 * time.Hour
+	DefaultCertValidity = 365 * 24 * time.Hour
+	DefaultCRLValidity  = 7 * 24 * time.Hour
+)
+
+var (
+	ErrCSRSignature = errors.New("certificate request signature invalid")
+	ErrNotCA        = errors.New("certificate is not a CA")
+)
+
+// CertOptions 证书参数, Hosts会按IP和域名自动拆分到SAN
+type CertOptions struct {
+	CommonName   string
+	Organization []string
+	Hosts        []string
+	DNSNames     []string
+	IPAddresses  []net.IP
+	Emails       []string
+	URIs         []*url.URL
+
+	// Validity 有效期, 为0时CA默认10年, 其他证书默认1年
+	Validity time.Duration
+	// KeyUsage/ExtKeyUsage 为空时按证书类型设置
+	KeyUsage    x509.KeyUsage
+	ExtKeyUsage []x509.ExtKeyUsage
+	// KeyAlgorithm 生成密钥的算法, 为空时为2048位RSA
+	KeyAlgorithm SignAlgorithm
+}
+
+func (o *CertOptions) template(validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	if o.Validity > 0 {
+		validity = o.Validity
+	}
+
+	now := time.Now()
+	tpl := &x509.Certificate{
+		SerialNumber:   serial,
+		Subject:        pkix.Name{CommonName: o.CommonName, Organization: o.Organization},
+		NotBefore:      now.Add(-5 * time.Minute),
+		NotAfter:       now.Add(validity),
+		DNSNames:       append([]string{}, o.DNSNames...),
+		IPAddresses:    append([]net.IP{}, o.IPAddresses...),
+		EmailAddresses: o.Emails,
+		URIs:           o.URIs,
+		KeyUsage:       o.KeyUsage,
+		ExtKeyUsage:    o.ExtKeyUsage,
+	}
+	for _, host := range o.Hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			tpl.IPAddresses = append(tpl.IPAddresses, ip)
+		} else {
+			tpl.DNSNames = append(tpl.DNSNames, host)
+		}
+	}
+	return tpl, nil
+}
+
+func (o *CertOptions) generateKey() (crypto.Signer, error) {
+	alg := o.KeyAlgorithm
+	if alg == "" {
+		alg = RS256
+	}
+	return GenerateSigningKey(alg)
+}
+
+// keyUsage RSA密钥需要KeyEncipherment用于TLS密钥交换
+func keyUsage(pub crypto.PublicKey) x509.KeyUsage {
+	if _, ok := pub.(*rsa.PublicKey); ok {
+		return x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	}
+	return x509.KeyUsageDigitalSignature
+}
+
+// Certificate 签发的证书和私钥, 私钥为空表示由CSR签发
+type Certificate struct {
+	Cert   *x509.Certificate
+	Key    crypto.Signer
+	CACert *x509.Certificate
+}
+
+// CertPEM 证书PEM
+func (c *Certificate) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeCertificate, Bytes: c.Cert.Raw})
+}
+
+// KeyPEM 私钥PEM(PKCS#8), passphrase不为空时加密
+func (c *Certificate) KeyPEM(passphrase []byte) ([]byte, error) {
+	if c.Key == nil {
+		return nil, ErrNoPrivateKey
+	}
+	return MarshalPrivateKeyPEM(c.Key, passphrase)
+}
+
+// CACertPEM 签发CA的证书PEM
+func (c *Certificate) CACertPEM() []byte {
+	if c.CACert == nil {
+		return nil
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeCertificate, Bytes: c.CACert.Raw})
+}
+
+// WriteFiles 写入 dir/name.crt、dir/name.key(0600)、dir/ca.crt
+func (c *Certificate) WriteFiles(dir, name string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(dir, name+".crt"), c.CertPEM(), 0644); err != nil {
+		return err
+	}
+	if c.Key != nil {
+		key, err := c.KeyPEM(nil)
+		if err != nil {
+			return err
+		}
+		defer zero(key)
+		if err := utils.WriteFileAtomic(filepath.Join(dir, name+".key"), key, 0600); err != nil {
+			return err
+		}
+	}
+	if ca := c.CACertPEM(); ca != nil && c.CACert != c.Cert {
+		return utils.WriteFileAtomic(filepath.Join(dir, "ca.crt"), ca, 0644)
+	}
+	return nil
+}
+
+// TLSSecret 生成kubernetes.io/tls类型的Secret, 包含tls.crt、tls.key和ca.crt
+func (c *Certificate) TLSSecret(namespace, name string) (*corev1.Secret, error) {
+	key, err := c.KeyPEM(nil)
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       c.CertPEM(),
+			corev1.TLSPrivateKeyKey: key,
+		},
+	}
+	if ca := c.CACertPEM(); ca != nil {
+		secret.Data[corev1.ServiceAccountRootCAKey] = ca
+	}
+	return secret, nil
+}
+
+// ApplyTLSSecret 创建或更新TLS Secret, 无法连接集群时返回错误
+func (c *Certificate) ApplyTLSSecret(namespace, name string) error {
+	client, err := clientgo.InitClientE()
+	if err != nil {
+		return err
+	}
+	return c.ApplyTLSSecretClient(context.Background(), client, namespace, name)
+}
+
+// ApplyTLSSecretClient 使用指定的client创建或更新TLS Secret
+func (c *Certificate) ApplyTLSSecretClient(ctx context.Context, client kubernetes.Interface, namespace, name string) error {
+	secret, err := c.TLSSecret(namespace, name)
+	if err != nil {
+		return err
+	}
+	defer zeroSecret(secret)
+	return applySecret(ctx, client, secret)
+}
+
+// applySecret Secret不存在时创建, 存在时整体替换type和data
+func applySecret(ctx context.Context, client kubernetes.Interface, secret *corev1.Secret) error {
+	namespace := secret.Namespace
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	secrets := client.CoreV1().Secrets(namespace)
+	current, err := secrets.Get(ctx, secret.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	current.Type = secret.Type
+	current.Data = secret.Data
+	current.StringData = secret.StringData
+	if current.Labels == nil {
+		current.Labels = secret.Labels
+	}
+	if current.Annotations == nil {
+		current.Annotations = secret.Annotations
+	}
+	_, err = secrets.Update(ctx, current, metav1.UpdateOptions{})
+	return err
+}
+
+// CA 本地证书颁发机构, 可以签发服务端、客户端证书和CRL
+type CA struct {
+	*Certificate
+
+	lock      sync.Mutex
+	revoked   map[string]x509.RevocationListEntry
+	crlNumber int64
+}
+
+// NewCA 创建自签名CA
+func NewCA(opts CertOptions) (*CA, error) {
+	key, err := opts.generateKey()
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := opts.template(DefaultCAValidity)
+	if err != nil {
+		return nil, err
+	}
+	tpl.IsCA = true
+	tpl.BasicConstraintsValid = true
+	if tpl.KeyUsage == 0 {
+		tpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Certificate: &Certificate{Cert: cert, Key: key, CACert: cert}, revoked: map[string]x509.RevocationListEntry{}}, nil
+}
+
+// LoadCA 从PEM加载CA证书和私钥
+// 吊销列表和CRL number不在证书中, 需要用LoadCRL从上次生成的CRL恢复, 否则重新从空列表开始
+func LoadCA(certPEM, keyPEM, passphrase []byte) (*CA, error) {
+	cert, err := ParseCertificatePEM(certPEM)
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, ErrNotCA
+	}
+	key, err := ParsePrivateKeyPEM(keyPEM, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if !publicKeyEqual(cert.PublicKey, key.Public()) {
+		return nil, errors.New("ca certificate and private key do not match")
+	}
+	return &CA{Certificate: &Certificate{Cert: cert, Key: key, CACert: cert}, revoked: map[string]x509.RevocationListEntry{}}, nil
+}
+
+// LoadCAFromFiles 读取 dir/ca.crt 和 dir/ca.key, dir/ca.crl 存在时恢复吊销列表
+func LoadCAFromFiles(dir string, passphrase []byte) (*CA, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, "ca.key"))
+	if err != nil {
+		return nil, err
+	}
+	defer zero(keyPEM)
+	ca, err := LoadCA(certPEM, keyPEM, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	crlPEM, err := os.ReadFile(filepath.Join(dir, "ca.crl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return ca, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := ca.LoadCRL(crlPEM); err != nil {
+		return nil, err
+	}
+	return ca, nil
+}
+
+// LoadCRL 从该CA签发的CRL恢复吊销列表和CRL number, 用于重启后继续吊销
+func (ca *CA) LoadCRL(crlPEM []byte) error {
+	crl, err := ParseCRLPEM(crlPEM, ca.Cert)
+	if err != nil {
+		return err
+	}
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.revoked == nil {
+		ca.revoked = map[string]x509.RevocationListEntry{}
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		ca.revoked[entry.SerialNumber.String()] = x509.RevocationListEntry{SerialNumber: entry.SerialNumber, RevocationTime: entry.RevocationTime}
+	}
+	if crl.Number != nil && crl.Number.IsInt64() && crl.Number.Int64() > ca.crlNumber {
+		ca.crlNumber = crl.Number.Int64()
+	}
+	return nil
+}
+
+// CertPool 只包含当前CA的证书池, 用于mTLS校验对端
+func (ca *CA) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	return pool
+}
+
+// IssueServer 签发服务端证书, 生成新的私钥
+func (ca *CA) IssueServer(opts CertOptions) (*Certificate, error) {
+	if len(opts.ExtKeyUsage) == 0 {
+		opts.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	return ca.issueWithKey(opts)
+}
+
+// IssueClient 签发客户端证书, 用于mTLS
+func (ca *CA) IssueClient(opts CertOptions) (*Certificate, error) {
+	if len(opts.ExtKeyUsage) == 0 {
+		opts.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	return ca.issueWithKey(opts)
+}
+
+func (ca *CA) issueWithKey(opts CertOptions) (*Certificate, error) {
+	key, err := opts.generateKey()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := ca.Issue(opts, key.Public())
+	if err != nil {
+		return nil, err
+	}
+	cert.Key = key
+	return cert, nil
+}
+
+// Issue 为公钥签发证书, ExtKeyUsage为空时同时用于服务端和客户端
+func (ca *CA) Issue(opts CertOptions, pub crypto.PublicKey) (*Certificate, error) {
+	tpl, err := opts.template(DefaultCertValidity)
+	if err != nil {
+		return nil, err
+	}
+	if tpl.KeyUsage == 0 {
+		tpl.KeyUsage = keyUsage(pub)
+	}
+	if len(tpl.ExtKeyUsage) == 0 {
+		tpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	}
+	// 证书有效期不能超过CA
+	if tpl.NotAfter.After(ca.Cert.NotAfter) {
+		tpl.NotAfter = ca.Cert.NotAfter
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca.Cert, pub, ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Certificate{Cert: cert, CACert: ca.Cert}, nil
+}
+
+// SignCSR 校验CSR签名并签发证书, opts中未设置的CommonName、SAN使用CSR中的值
+func (ca *CA) SignCSR(csrPEM []byte, opts CertOptions) (*Certificate, error) {
+	csr, err := ParseCSRPEM(csrPEM)
+	if err != nil {
+		return nil, err
+	}
+	if opts.CommonName == "" {
+		opts.CommonName = csr.Subject.CommonName
+	}
+	if len(opts.Organization) == 0 {
+		opts.Organization = csr.Subject.Organization
+	}
+	if len(opts.Hosts) == 0 && len(opts.DNSNames) == 0 && len(opts.IPAddresses) == 0 {
+		opts.DNSNames = csr.DNSNames
+		opts.IPAddresses = csr.IPAddresses
+	}
+	if len(opts.Emails) == 0 {
+		opts.Emails = csr.EmailAddresses
+	}
+	if len(opts.URIs) == 0 {
+		opts.URIs = csr.URIs
+	}
+	return ca.Issue(opts, csr.PublicKey)
+}
+
+// Revoke 吊销证书, 下次生成的CRL中生效
+func (ca *CA) Revoke(serial *big.Int, at time.Time) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+	if ca.revoked == nil {
+		ca.revoked = map[string]x509.RevocationListEntry{}
+	}
+	ca.revoked[serial.String()] = x509.RevocationListEntry{SerialNumber: serial, RevocationTime: at}
+}
+
+// CRL 生成PEM格式的CRL, validity为0时默认7天, 每次生成CRL number递增
+func (ca *CA) CRL(validity time.Duration) ([]byte, error) {
+	if validity <= 0 {
+		validity = DefaultCRLValidity
+	}
+
+	ca.lock.Lock()
+	ca.crlNumber++
+	entries := make([]x509.RevocationListEntry, 0, len(ca.revoked))
+	for _, entry := range ca.revoked {
+		entries = append(entries, entry)
+	}
+	number := ca.crlNumber
+	ca.lock.Unlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].SerialNumber.Cmp(entries[j].SerialNumber) < 0
+	})
+
+	now := time.Now()
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(number),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(validity),
+		RevokedCertificateEntries: entries,
+	}, ca.Cert, ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeCRL, Bytes: der}), nil
+}
+
+// WriteFiles 写入 dir/ca.crt 和 dir/ca.key(0600), 私钥使用passphrase加密
+// 吊销过证书或生成过CRL时同时写入新的 dir/ca.crl, LoadCAFromFiles从中恢复吊销列表
+func (ca *CA) WriteFiles(dir string, passphrase []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	key, err := ca.KeyPEM(passphrase)
+	if err != nil {
+		return err
+	}
+	defer zero(key)
+	if err := utils.WriteFileAtomic(filepath.Join(dir, "ca.key"), key, 0600); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(dir, "ca.crt"), ca.CertPEM(), 0644); err != nil {
+		return err
+	}
+
+	ca.lock.Lock()
+	stateful := len(ca.revoked) > 0 || ca.crlNumber > 0
+	ca.lock.Unlock()
+	if !stateful {
+		return nil
+	}
+	crl, err := ca.CRL(0)
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, "ca.crl"), crl, 0644)
+}
+
+// ParseCertificatePEM 解析PEM中的第一张证书
+func ParseCertificatePEM(pemData []byte) (*x509.Certificate, error) {
+	certs, err := ParseCertificatesPEM(pemData)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// ParseCertificatesPEM 解析PEM中的所有证书, 忽略其他类型的block
+func ParseCertificatesPEM(pemData []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, pemData = pem.Decode(pemData)
+		if block == nil {
+			break
+		}
+		if block.Type != PEMTypeCertificate {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, ErrPEM
+	}
+	return certs, nil
+}
+
+// ParseCSRPEM 解析并校验CSR签名
+func ParseCSRPEM(pemData []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, ErrPEM
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCSRSignature, err)
+	}
+	return csr, nil
+}
+
+// ParseCRLPEM 解析CRL, issuer不为空时校验签名
+func ParseCRLPEM(pemData []byte, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, ErrPEM
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if issuer != nil {
+		if err := crl.CheckSignatureFrom(issuer); err != nil {
+			return nil, err
+		}
+	}
+	return crl, nil
+}
+
+// CertificateExpiry 证书过期信息
+type CertificateExpiry struct {
+	Subject   string        `json:"subject"`
+	Serial    string        `json:"serial"`
+	NotAfter  time.Time     `json:"notAfter"`
+	Remaining time.Duration `json:"remaining"`
+	Expired   bool          `json:"expired"`
+	// Expiring 在检查窗口内即将过期
+	Expiring bool `json:"expiring"`
+}
+
+// CheckExpiry 检查PEM中所有证书的过期时间, within为即将过期的提醒窗口
+func CheckExpiry(pemData []byte, within time.Duration) ([]CertificateExpiry, error) {
+	certs, err := ParseCertificatesPEM(pemData)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make([]CertificateExpiry, 0, len(certs))
+	for _, cert := range certs {
+		remaining := cert.NotAfter.Sub(now)
+		result = append(result, CertificateExpiry{
+			Subject:   cert.Subject.String(),
+			Serial:    cert.SerialNumber.String(),
+			NotAfter:  cert.NotAfter,
+			Remaining: remaining,
+			Expired:   remaining <= 0,
+			Expiring:  remaining > 0 && remaining <= within,
+		})
+	}
+	return result, nil
+}
+
+// publicKeyEqual 标准库的公钥类型都实现了Equal
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	k, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && k.Equal(b)
+}
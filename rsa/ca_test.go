@@ -0,0 +1,171 @@
+package rsa
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_CAIssueMTLS(t *testing.T) {
+	ca, err := NewCA(CertOptions{CommonName: "lflxp ca", KeyAlgorithm: ES256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := ca.IssueServer(CertOptions{CommonName: "proxy", Hosts: []string{"127.0.0.1", "localhost"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := ca.IssueClient(CertOptions{CommonName: "webhook", KeyAlgorithm: EdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(server.Cert.IPAddresses) != 1 || server.Cert.DNSNames[0] != "localhost" {
+		t.Fatalf("unexpected san %v %v", server.Cert.IPAddresses, server.Cert.DNSNames)
+	}
+
+	dir := t.TempDir()
+	if err := ca.WriteFiles(dir, []byte("lflxp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.WriteFiles(dir, "server"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "server.key")); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected key file %v %v", info, err)
+	}
+	loaded, err := LoadCAFromFiles(dir, []byte("lflxp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverPair, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKey, _ := client.KeyPEM(nil)
+	clientPair, err := tls.X509KeyPair(client.CertPEM(), clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{serverPair}, ClientCAs: loaded.CertPool(), ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      ca.CertPool(),
+		Certificates: []tls.Certificate{clientPair},
+	}}}
+	resp, err := httpClient.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func Test_CASignCSRAndCRL(t *testing.T) {
+	ca, err := NewCA(CertOptions{CommonName: "lflxp ca"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := GenerateSigningKey(ES256)
+	csrDER, err := x509.CreateCertificateRequest(nil, &x509.CertificateRequest{
+		Subject:     pkix.Name{CommonName: "svc"},
+		DNSNames:    []string{"svc.default.svc"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := ca.SignCSR(pem.EncodeToMemory(&pem.Block{Type: PEMTypeCertificateRequest, Bytes: csrDER}), CertOptions{Validity: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Cert.Subject.CommonName != "svc" || cert.Cert.DNSNames[0] != "svc.default.svc" {
+		t.Fatalf("unexpected certificate %v", cert.Cert.Subject)
+	}
+	if _, err := cert.Cert.Verify(x509.VerifyOptions{Roots: ca.CertPool(), DNSName: "svc.default.svc"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expiry, err := CheckExpiry(append(cert.CertPEM(), ca.CertPEM()...), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expiry) != 2 || !expiry[0].Expiring || expiry[1].Expiring || expiry[0].Expired {
+		t.Fatalf("unexpected expiry %+v", expiry)
+	}
+
+	ca.Revoke(cert.Cert.SerialNumber, time.Now())
+	crlPEM, err := ca.CRL(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := ParseCRLPEM(crlPEM, ca.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crl.RevokedCertificateEntries) != 1 || crl.RevokedCertificateEntries[0].SerialNumber.Cmp(cert.Cert.SerialNumber) != 0 {
+		t.Fatalf("unexpected crl entries %+v", crl.RevokedCertificateEntries)
+	}
+
+	// 重新加载后吊销列表和CRL number不丢失
+	dir := t.TempDir()
+	if err := ca.WriteFiles(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCAFromFiles(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crlPEM, err = loaded.CRL(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crl, err = ParseCRLPEM(crlPEM, ca.Cert); err != nil || len(crl.RevokedCertificateEntries) != 1 || crl.Number.Int64() != 3 {
+		t.Fatalf("unexpected reloaded crl %+v %v", crl, err)
+	}
+
+	// CSR由其他人签发的私钥签名, 不能导出tls secret
+	if _, err := cert.TLSSecret("default", "svc-tls"); err == nil {
+		t.Fatal("expected error without private key")
+	}
+	server, _ := ca.IssueServer(CertOptions{CommonName: "svc"})
+	secret, err := server.TLSSecret("default", "svc-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Type != corev1.SecretTypeTLS || len(secret.Data[corev1.TLSPrivateKeyKey]) == 0 {
+		t.Fatalf("unexpected secret %+v", secret)
+	}
+
+	// 第二次apply更新已有的Secret
+	client := fake.NewSimpleClientset()
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := server.ApplyTLSSecretClient(ctx, client, "default", "svc-tls"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	applied, err := client.CoreV1().Secrets("default").Get(ctx, "svc-tls", metav1.GetOptions{})
+	if err != nil || string(applied.Data[corev1.TLSCertKey]) != string(server.CertPEM()) {
+		t.Fatalf("unexpected applied secret %v", err)
+	}
+}
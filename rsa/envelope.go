@@ -0,0 +1,304 @@
+package rsa
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 信封加密格式, 所有整数为大端序
+//
+//	v1: magic "LXEV" | 1 | chunkSize(4) | keyLen(2) | RSA-OAEP-SHA256(aesKey) | noncePrefix(7)
+//	v2: magic "LXEV" | 2 | kidLen(1) | kid | chunkSize(4) | keyLen(2) | RSA-OAEP-SHA256(aesKey) | noncePrefix(7)
+//	chunk... 每块为AES-256-GCM密文, nonce = noncePrefix | counter(4) | last(1)
+//
+// v2 额外携带key ID, 供KeyRing轮换密钥后找到对应私钥
+// header整体作为每个chunk的AAD, 最后一块的last=1, 防止截断和重排
+const (
+	envelopeVersion1     byte = 1
+	envelopeVersion2     byte = 2
+	envelopeKeySize           = 32
+	envelopeNoncePrefix       = 7
+	envelopeChunkSize         = 64 * 1024
+	envelopeMaxChunkSize      = 16 * 1024 * 1024
+)
+
+var envelopeMagic = []byte("LXEV")
+
+var (
+	ErrEnvelopeFormat    = errors.New("envelope: invalid format")
+	ErrEnvelopeVersion   = errors.New("envelope: unsupported version")
+	ErrEnvelopeTruncated = errors.New("envelope: truncated ciphertext")
+	ErrEnvelopeKey       = errors.New("envelope: no key can decrypt")
+)
+
+// IsEnvelope 判断数据是否为信封加密格式
+func IsEnvelope(data []byte) bool {
+	return len(data) > len(envelopeMagic) && bytes.HasPrefix(data, envelopeMagic)
+}
+
+// EnvelopeEncrypt 使用随机AES-256-GCM密钥加密数据, 再用RSA-OAEP加密该密钥, 不受RSA密钥长度限制
+func EnvelopeEncrypt(pub *rsa.PublicKey, plaintext []byte) ([]byte, error) {
+	var out bytes.Buffer
+	if err := EnvelopeEncryptStream(pub, &out, bytes.NewReader(plaintext)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// EnvelopeDecrypt 解密EnvelopeEncrypt的输出
+func EnvelopeDecrypt(priv *rsa.PrivateKey, ciphertext []byte) ([]byte, error) {
+	var out bytes.Buffer
+	if err := EnvelopeDecryptStream(priv, &out, bytes.NewReader(ciphertext)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// EnvelopeEncryptStream 流式加密src写入dst, 内存占用与数据大小无关
+func EnvelopeEncryptStream(pub *rsa.PublicKey, dst io.Writer, src io.Reader) error {
+	return envelopeEncrypt(pub, "", dst, src)
+}
+
+// envelopeEncrypt kid为空时输出v1, 否则输出携带kid的v2
+func envelopeEncrypt(pub *rsa.PublicKey, kid string, dst io.Writer, src io.Reader) error {
+	if len(kid) > 255 {
+		return errors.New("envelope: key id too long")
+	}
+
+	key := make([]byte, envelopeKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+	defer zero(key)
+
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, nil)
+	if err != nil {
+		return err
+	}
+
+	prefix := make([]byte, envelopeNoncePrefix)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, len(envelopeMagic)+2+len(kid)+4+2+len(wrapped)+len(prefix))
+	header = append(header, envelopeMagic...)
+	if kid == "" {
+		header = append(header, envelopeVersion1)
+	} else {
+		header = append(header, envelopeVersion2, byte(len(kid)))
+		header = append(header, kid...)
+	}
+	header = binary.BigEndian.AppendUint32(header, envelopeChunkSize)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(wrapped)))
+	header = append(header, wrapped...)
+	header = append(header, prefix...)
+
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	in := bufio.NewReaderSize(src, envelopeChunkSize)
+	buf := make([]byte, envelopeChunkSize)
+	sealed := make([]byte, 0, envelopeChunkSize+aead.Overhead())
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(in, buf)
+		last := false
+		switch err {
+		case nil:
+			if _, perr := in.Peek(1); perr == io.EOF {
+				last = true
+			} else if perr != nil {
+				return perr
+			}
+		case io.EOF, io.ErrUnexpectedEOF:
+			last = true
+		default:
+			return err
+		}
+
+		sealed = aead.Seal(sealed[:0], chunkNonce(prefix, counter, last), buf[:n], header)
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+		if counter == ^uint32(0) {
+			return errors.New("envelope: too many chunks")
+		}
+	}
+}
+
+// EnvelopeDecryptStream 流式解密, 任何一块校验失败都会返回错误
+// 注意: 出错前已写入dst的数据不可信, 调用方需要丢弃
+func EnvelopeDecryptStream(priv *rsa.PrivateKey, dst io.Writer, src io.Reader) error {
+	return envelopeDecrypt(func(string) []*rsa.PrivateKey { return []*rsa.PrivateKey{priv} }, dst, src)
+}
+
+// EnvelopeKeyID 返回密文头中的key ID, v1格式返回空字符串
+func EnvelopeKeyID(ciphertext []byte) (string, error) {
+	header, err := readEnvelopeHeader(bufio.NewReader(bytes.NewReader(ciphertext)))
+	if err != nil {
+		return "", err
+	}
+	return header.kid, nil
+}
+
+type envelopeHeader struct {
+	raw       []byte
+	kid       string
+	chunkSize uint32
+	wrapped   []byte
+	prefix    []byte
+}
+
+func readEnvelopeHeader(in *bufio.Reader) (*envelopeHeader, error) {
+	raw := make([]byte, len(envelopeMagic)+1)
+	if _, err := io.ReadFull(in, raw); err != nil {
+		return nil, ErrEnvelopeFormat
+	}
+	if !bytes.Equal(raw[:len(envelopeMagic)], envelopeMagic) {
+		return nil, ErrEnvelopeFormat
+	}
+
+	header := &envelopeHeader{}
+	switch version := raw[len(envelopeMagic)]; version {
+	case envelopeVersion1:
+	case envelopeVersion2:
+		kidLen, err := in.ReadByte()
+		if err != nil {
+			return nil, ErrEnvelopeTruncated
+		}
+		kid := make([]byte, kidLen)
+		if _, err := io.ReadFull(in, kid); err != nil {
+			return nil, ErrEnvelopeTruncated
+		}
+		raw = append(append(raw, kidLen), kid...)
+		header.kid = string(kid)
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrEnvelopeVersion, version)
+	}
+
+	fixed := make([]byte, 4+2)
+	if _, err := io.ReadFull(in, fixed); err != nil {
+		return nil, ErrEnvelopeTruncated
+	}
+	header.chunkSize = binary.BigEndian.Uint32(fixed)
+	keyLen := binary.BigEndian.Uint16(fixed[4:])
+	if header.chunkSize == 0 || header.chunkSize > envelopeMaxChunkSize {
+		return nil, ErrEnvelopeFormat
+	}
+
+	rest := make([]byte, int(keyLen)+envelopeNoncePrefix)
+	if _, err := io.ReadFull(in, rest); err != nil {
+		return nil, ErrEnvelopeTruncated
+	}
+	header.wrapped = rest[:keyLen]
+	header.prefix = rest[keyLen:]
+	header.raw = append(append(raw, fixed...), rest...)
+	return header, nil
+}
+
+// envelopeDecrypt keys按kid返回候选私钥, 依次尝试解开AES密钥
+func envelopeDecrypt(keys func(kid string) []*rsa.PrivateKey, dst io.Writer, src io.Reader) error {
+	in := bufio.NewReaderSize(src, envelopeChunkSize)
+
+	h, err := readEnvelopeHeader(in)
+	if err != nil {
+		return err
+	}
+	header, prefix, chunkSize := h.raw, h.prefix, h.chunkSize
+
+	var key []byte
+	for _, priv := range keys(h.kid) {
+		if priv == nil {
+			continue
+		}
+		if key, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, h.wrapped, nil); err == nil {
+			break
+		}
+	}
+	if key == nil {
+		if h.kid != "" {
+			return fmt.Errorf("%w: kid %s", ErrEnvelopeKey, h.kid)
+		}
+		return ErrEnvelopeKey
+	}
+	defer zero(key)
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, int(chunkSize)+aead.Overhead())
+	plain := make([]byte, 0, chunkSize)
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(in, buf)
+		last := false
+		switch err {
+		case nil:
+			if _, perr := in.Peek(1); perr == io.EOF {
+				last = true
+			} else if perr != nil {
+				return perr
+			}
+		case io.ErrUnexpectedEOF:
+			last = true
+		case io.EOF:
+			return ErrEnvelopeTruncated
+		default:
+			return err
+		}
+
+		plain, err = aead.Open(plain[:0], chunkNonce(prefix, counter, last), buf[:n], header)
+		if err != nil {
+			return err
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 0, 12)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+	if last {
+		return append(nonce, 1)
+	}
+	return append(nonce, 0)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
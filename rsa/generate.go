@@ -0,0 +1,172 @@
+package rsa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"xorm.io/xorm"
+
+	"github.com/lflxp/tools/sdk/clientgo"
+	"github.com/lflxp/tools/utils"
+)
+
+// GenerateKeyPair 的文件名
+const (
+	PrivateKeyFile = "private.pem"
+	PublicKeyFile  = "public.pem"
+)
+
+// GeneratedKey 新生成的RSA密钥, 只保存在内存中, 需要时再写入文件、Secret或数据库
+// PrivatePEM为PKCS#8格式, 设置了passphrase时为加密PEM
+type GeneratedKey struct {
+	ID         string
+	Private    *rsa.PrivateKey
+	PrivatePEM []byte
+	PublicPEM  []byte
+}
+
+// MinKeyBits GenerateKeyPair允许的最小RSA密钥长度
+const MinKeyBits = 2048
+
+var ErrKeySize = fmt.Errorf("rsa key size must be at least %d bits", MinKeyBits)
+
+// GenerateKeyPair 生成RSA密钥, bits小于MinKeyBits时返回ErrKeySize
+func GenerateKeyPair(bits int, passphrase []byte) (*GeneratedKey, error) {
+	if bits < MinKeyBits {
+		return nil, fmt.Errorf("%w: %d", ErrKeySize, bits)
+	}
+	return generateKeyPair(bits, passphrase)
+}
+
+// generateKeyPair 不检查MinKeyBits, 只用于兼容旧接口
+func generateKeyPair(bits int, passphrase []byte) (*GeneratedKey, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	privPEM, err := MarshalPrivateKeyPEM(priv, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	pubPEM, err := MarshalPublicKeyPEM(&priv.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &GeneratedKey{ID: KeyID(&priv.PublicKey), Private: priv, PrivatePEM: privPEM, PublicPEM: pubPEM}, nil
+}
+
+// Key 转换为KeyRing中的密钥
+func (g *GeneratedKey) Key(active bool) *Key {
+	return &Key{ID: g.ID, Private: g.Private, Active: active}
+}
+
+// WriteFiles 原子写入 dir/private.pem(0600) 和 dir/public.pem(0644)
+func (g *GeneratedKey) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(dir, PrivateKeyFile), g.PrivatePEM, 0600); err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(filepath.Join(dir, PublicKeyFile), g.PublicPEM, 0644)
+}
+
+// SaveSecret 以 <kid>.pem 写入Secret, 与LoadKeyRingFromSecret格式一致, 保留已有的其他密钥
+// active为true时同时更新 active, 无法连接集群时返回错误
+func (g *GeneratedKey) SaveSecret(namespace, name string, active bool) error {
+	client, err := clientgo.InitClientE()
+	if err != nil {
+		return err
+	}
+	return g.SaveSecretClient(context.Background(), client, namespace, name, active)
+}
+
+// SaveSecretClient 使用指定的client写入Secret
+func (g *GeneratedKey) SaveSecretClient(ctx context.Context, client kubernetes.Interface, namespace, name string, active bool) error {
+	secrets := client.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	notFound := apierrors.IsNotFound(err)
+	if err != nil && !notFound {
+		return err
+	}
+	if notFound {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Type:       corev1.SecretTypeOpaque,
+		}
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[g.ID+".pem"] = g.PrivatePEM
+	if active {
+		secret.Data["active"] = []byte(g.ID)
+	}
+
+	if notFound {
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+	} else {
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// RsaKey sqlite中保存的密钥
+type RsaKey struct {
+	Kid        string    `xorm:"pk varchar(255)" json:"kid"`
+	PrivateKey string    `xorm:"text" json:"-"`
+	PublicKey  string    `xorm:"text" json:"publicKey"`
+	Active     bool      `json:"active"`
+	Created    time.Time `xorm:"created" json:"created"`
+}
+
+// SaveDB 保存到数据库, active为true时取消其他密钥的active
+func (g *GeneratedKey) SaveDB(engine *xorm.Engine, active bool) error {
+	if err := engine.Sync2(new(RsaKey)); err != nil {
+		return err
+	}
+	session := engine.NewSession()
+	defer session.Close()
+	if err := session.Begin(); err != nil {
+		return err
+	}
+	if active {
+		if _, err := session.Where("Active = ?", true).Cols("Active").Update(&RsaKey{Active: false}); err != nil {
+			session.Rollback()
+			return err
+		}
+	}
+	row := &RsaKey{Kid: g.ID, PrivateKey: string(g.PrivatePEM), PublicKey: string(g.PublicPEM), Active: active}
+	if _, err := session.Insert(row); err != nil {
+		session.Rollback()
+		return err
+	}
+	return session.Commit()
+}
+
+// LoadKeyRingFromDB 读取SaveDB保存的密钥
+func LoadKeyRingFromDB(engine *xorm.Engine, passphrase []byte) (*KeyRing, error) {
+	if err := engine.Sync2(new(RsaKey)); err != nil {
+		return nil, err
+	}
+	rows := []RsaKey{}
+	if err := engine.Asc("Created").Find(&rows); err != nil {
+		return nil, err
+	}
+	r := NewKeyRing()
+	for _, row := range rows {
+		if err := r.AddPEMWithPassphrase(row.Kid, []byte(row.PrivateKey), passphrase, row.Active); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
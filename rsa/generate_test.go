@@ -0,0 +1,101 @@
+package rsa
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+	_ "modernc.org/sqlite"
+	"xorm.io/xorm"
+)
+
+// 旧接口保持原有行为, 不限制bits
+func Test_GenerateRSAKeyLegacyBits(t *testing.T) {
+	wd, _ := os.Getwd()
+	oldPriv, oldPub := PrivateKey, PublicKey
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		PrivateKey, PublicKey = oldPriv, oldPub
+	})
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateRSAKey(1024); err != nil {
+		t.Fatal(err)
+	}
+	priv, err := ParsePrivateKey(PrivateKey)
+	if err != nil || priv.N.BitLen() != 1024 {
+		t.Fatalf("unexpected key %v", err)
+	}
+}
+
+func Test_GenerateKeyPair(t *testing.T) {
+	if _, err := GenerateKeyPair(1024, nil); !errors.Is(err, ErrKeySize) {
+		t.Fatalf("expected ErrKeySize, got %v", err)
+	}
+	key, err := GenerateKeyPair(2048, []byte("lflxp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParsePrivateKey(string(key.PrivatePEM)); err == nil {
+		t.Fatal("expected encrypted private key")
+	}
+
+	dir := filepath.Join(t.TempDir(), "keys")
+	if err := key.WriteFiles(dir); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, PrivateKeyFile))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected private key file %v %v", info, err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 2 {
+		t.Fatalf("unexpected files %v", files)
+	}
+
+	engine, err := xorm.NewEngine("sqlite", filepath.Join(t.TempDir(), "keys.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	if err := key.SaveDB(engine, true); err != nil {
+		t.Fatal(err)
+	}
+	rotated, _ := GenerateKeyPair(2048, []byte("lflxp"))
+	if err := rotated.SaveDB(engine, true); err != nil {
+		t.Fatal(err)
+	}
+
+	ring, err := LoadKeyRingFromDB(engine, []byte("lflxp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := ring.Active()
+	if err != nil || active.ID != rotated.ID || len(ring.Keys()) != 2 {
+		t.Fatalf("unexpected ring %v %v", active, err)
+	}
+}
+
+func Test_GeneratedKeySaveSecret(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewSimpleClientset()
+	first, _ := GenerateKeyPair(2048, nil)
+	second, _ := GenerateKeyPair(2048, nil)
+	if err := first.SaveSecretClient(ctx, client, "default", "keys", true); err != nil {
+		t.Fatal(err)
+	}
+	// 已有的密钥保留
+	if err := second.SaveSecretClient(ctx, client, "default", "keys", false); err != nil {
+		t.Fatal(err)
+	}
+	ring, err := LoadKeyRingFromSecretClient(ctx, client, "default", "keys", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active, err := ring.Active(); err != nil || active.ID != first.ID || len(ring.Keys()) != 2 {
+		t.Fatalf("unexpected ring %v %v", active, err)
+	}
+}
@@ -0,0 +1,293 @@
+package rsa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/httpclient"
+)
+
+// JWKSPath jwks的标准发布路径
+const JWKSPath = "/.well-known/jwks.json"
+
+// JWKAlgRSAOAEP256 用途为enc的RSA密钥发布的alg, 与信封加密的RSA-OAEP-SHA256一致
+const JWKAlgRSAOAEP256 = "RSA-OAEP-256"
+
+var ErrJWK = errors.New("invalid jwk")
+
+// JWK 公钥的JSON Web Key表示(RFC 7517), 支持RSA、EC(P-256/P-384)、OKP(Ed25519)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC / OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func unb64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// NewJWK 将公钥转换为JWK, 只导出公钥参数
+func NewJWK(pub crypto.PublicKey, kid, alg, use string) (JWK, error) {
+	jwk := JWK{Kid: kid, Alg: alg, Use: use}
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(k.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = k.Curve.Params().Name
+		jwk.X = b64(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(k)
+	default:
+		return JWK{}, fmt.Errorf("%w: %T", ErrKeyType, pub)
+	}
+	return jwk, nil
+}
+
+// PublicKey 将JWK还原为公钥
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := unb64(j.N)
+		if err != nil || len(n) == 0 {
+			return nil, fmt.Errorf("%w: rsa n", ErrJWK)
+		}
+		e, err := unb64(j.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("%w: rsa e", ErrJWK)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("%w: curve %s", ErrJWK, j.Crv)
+		}
+		x, err := unb64(j.X)
+		if err != nil {
+			return nil, fmt.Errorf("%w: ec x", ErrJWK)
+		}
+		y, err := unb64(j.Y)
+		if err != nil {
+			return nil, fmt.Errorf("%w: ec y", ErrJWK)
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("%w: point not on curve", ErrJWK)
+		}
+		return pub, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %s", ErrJWK, j.Crv)
+		}
+		x, err := unb64(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: okp x", ErrJWK)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: kty %s", ErrJWK, j.Kty)
+	}
+}
+
+// Thumbprint 按RFC 7638计算JWK thumbprint(SHA-256, base64url)
+func (j JWK) Thumbprint() string {
+	var members string
+	switch j.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, j.E, j.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, j.Crv, j.X, j.Y)
+	default:
+		members = fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s"}`, j.Crv, j.Kty, j.X)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return b64(sum[:])
+}
+
+// Thumbprint 计算公钥的RFC 7638 thumbprint
+func Thumbprint(pub crypto.PublicKey) (string, error) {
+	jwk, err := NewJWK(pub, "", "", "")
+	if err != nil {
+		return "", err
+	}
+	return jwk.Thumbprint(), nil
+}
+
+// JWK 导出key的公钥, use为空时默认sig, alg为空时按use和密钥类型推导
+func (k *Key) JWK() (JWK, error) {
+	use := k.Use
+	if use == "" {
+		use = KeyUseSig
+	}
+
+	alg := string(k.Alg)
+	if alg == "" {
+		if use == KeyUseEnc {
+			alg = JWKAlgRSAOAEP256
+		} else {
+			def, err := DefaultAlgorithm(k.PublicKey())
+			if err != nil {
+				return JWK{}, err
+			}
+			alg = string(def)
+		}
+	}
+	return NewJWK(k.PublicKey(), k.ID, alg, use)
+}
+
+// JWKS 导出所有公钥, 按创建时间排序
+func (r *KeyRing) JWKS() (*JWKS, error) {
+	jwks := &JWKS{Keys: []JWK{}}
+	for _, key := range r.Keys() {
+		jwk, err := key.JWK()
+		if err != nil {
+			return nil, err
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks, nil
+}
+
+// ImportJWKS 导入JWKS中的公钥用于验签和加密, 已存在的kid会被覆盖
+// kid为空时使用thumbprint; 先校验所有密钥(kty、use以及RSA长度不小于MinKeyBits), 任一无效时不导入
+func (r *KeyRing) ImportJWKS(jwks *JWKS) error {
+	keys := make([]*Key, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			return err
+		}
+		key := &Key{ID: jwk.Kid, Use: jwk.Use, VerifyKey: pub}
+		if jwk.Kid == "" {
+			key.ID = jwk.Thumbprint()
+		}
+		if rsaPub, ok := pub.(*rsa.PublicKey); ok && rsaPub.N.BitLen() < MinKeyBits {
+			return fmt.Errorf("import jwk %s: %w: %d", key.ID, ErrKeySize, rsaPub.N.BitLen())
+		}
+		// 加密算法(RSA-OAEP等)不是签名算法, 不记录
+		if checkAlgorithm(pub, SignAlgorithm(jwk.Alg)) == nil {
+			key.Alg = SignAlgorithm(jwk.Alg)
+		}
+		if err := prepareKey(key); err != nil {
+			return fmt.Errorf("import jwk %s: %w", key.ID, err)
+		}
+		keys = append(keys, key)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, key := range keys {
+		r.store(key)
+	}
+	return nil
+}
+
+// ParseJWKS 解析JWKS文档
+func ParseJWKS(data []byte) (*JWKS, error) {
+	jwks := &JWKS{}
+	if err := json.Unmarshal(data, jwks); err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+// LoadKeyRingFromJWKS 用JWKS文档创建只能验签的KeyRing
+func LoadKeyRingFromJWKS(data []byte) (*KeyRing, error) {
+	jwks, err := ParseJWKS(data)
+	if err != nil {
+		return nil, err
+	}
+	r := NewKeyRing()
+	if err := r.ImportJWKS(jwks); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FetchJWKS 从其他服务的jwks地址获取JWKS
+func FetchJWKS(url string) (*JWKS, error) {
+	jwks := &JWKS{}
+	err := httpclient.NewGoutClient().SetSkipVerify(false).GET(url).BindJSON(jwks).Do()
+	if err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+// RegisterJWKS 注册 GET /.well-known/jwks.json
+func RegisterJWKS(router gin.IRoutes, ring *KeyRing, maxAge time.Duration) {
+	router.GET(JWKSPath, JWKSHandler(ring, maxAge))
+}
+
+// JWKSHandler 发布KeyRing中的公钥, 带Cache-Control和ETag, 支持If-None-Match
+func JWKSHandler(ring *KeyRing, maxAge time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		jwks, err := ring.JWKS()
+		if err != nil {
+			httpclient.SendErrorMessage(c, http.StatusInternalServerError, httpclient.SystemError, err.Error())
+			return
+		}
+
+		body, err := json.Marshal(jwks)
+		if err != nil {
+			httpclient.SendErrorMessage(c, http.StatusInternalServerError, httpclient.JsonError, err.Error())
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		etag := `"` + b64(sum[:16]) + `"`
+		c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+		c.Header("ETag", etag)
+
+		if match := c.GetHeader("If-None-Match"); match != "" && strings.Contains(match, etag) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+		c.Data(http.StatusOK, "application/json", body)
+	}
+}
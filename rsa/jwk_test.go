@@ -0,0 +1,136 @@
+package rsa
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func Test_JWKSRoundTrip(t *testing.T) {
+	ring := NewKeyRing()
+	if err := ring.Add(&Key{ID: "rsa", Private: newTestKey(t), Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, alg := range []SignAlgorithm{ES256, ES384, EdDSA} {
+		key, err := GenerateSigningKey(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ring.Add(&Key{ID: string(alg), SigningKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jwks, err := ring.JWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 4 {
+		t.Fatalf("expected 4 keys, got %d", len(jwks.Keys))
+	}
+
+	// 导入方只持有公钥, 可以验签
+	public := NewKeyRing()
+	if err := public.ImportJWKS(jwks); err != nil {
+		t.Fatal(err)
+	}
+	for _, kid := range []string{"rsa", "ES256", "ES384", "EdDSA"} {
+		ring.SetActive(kid)
+		sig, err := ring.Sign([]byte("hello"))
+		if err != nil {
+			t.Fatalf("%s: %v", kid, err)
+		}
+		if err := public.Verify([]byte("hello"), sig); err != nil {
+			t.Fatalf("%s: %v", kid, err)
+		}
+	}
+
+	// kid为空时使用RFC 7638 thumbprint
+	tp, _ := Thumbprint(&newTestKey(t).PublicKey)
+	if err := public.ImportJWKS(&JWKS{Keys: []JWK{{Kty: jwks.Keys[0].Kty, N: jwks.Keys[0].N, E: jwks.Keys[0].E}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := public.Get(tp); !ok {
+		t.Fatalf("expected key with thumbprint %s", tp)
+	}
+}
+
+func Test_ImportJWKSValidation(t *testing.T) {
+	good, err := NewJWK(&newTestKey(t).PublicKey, "good", "", KeyUseSig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weak, err := NewJWK(&small.PublicKey, "weak", "", KeyUseSig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	badUse := good
+	badUse.Kid, badUse.Use = "bad-use", "both"
+
+	// 任一密钥无效时整体不导入
+	for _, jwk := range []JWK{weak, badUse} {
+		ring := NewKeyRing()
+		if err := ring.ImportJWKS(&JWKS{Keys: []JWK{good, jwk}}); err == nil {
+			t.Fatalf("%s: expected error", jwk.Kid)
+		}
+		if len(ring.Keys()) != 0 {
+			t.Fatalf("%s: keyring partially imported", jwk.Kid)
+		}
+	}
+
+	// use为enc的公钥不能用于验签
+	enc := good
+	enc.Use = KeyUseEnc
+	ring := NewKeyRing()
+	if err := ring.ImportJWKS(&JWKS{Keys: []JWK{enc}}); err != nil {
+		t.Fatal(err)
+	}
+	if key, _ := ring.Get("good"); key.Use != KeyUseEnc {
+		t.Fatalf("unexpected use %q", key.Use)
+	}
+	key, _ := ring.Get("good")
+	if _, err := key.Verifier(""); !errors.Is(err, ErrKeyUse) {
+		t.Fatalf("expected ErrKeyUse, got %v", err)
+	}
+}
+
+func Test_JWKSHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ring := NewKeyRing()
+	if err := ring.Add(&Key{ID: "rsa", Private: newTestKey(t), Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	RegisterJWKS(router, ring, time.Hour)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, JWKSPath, nil))
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+	}
+	loaded, err := LoadKeyRingFromJWKS(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Get("rsa"); !ok {
+		t.Fatal("expected kid rsa")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, JWKSPath, nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+}
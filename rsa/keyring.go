@@ -0,0 +1,586 @@
+package rsa
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/lflxp/tools/sdk/clientgo"
+)
+
+var (
+	ErrKeyNotFound  = errors.New("keyring: key not found")
+	ErrNoActiveKey  = errors.New("keyring: no active key")
+	ErrNoPrivateKey = errors.New("keyring: private key not loaded")
+	ErrKeyUse       = errors.New("keyring: key use not allowed")
+)
+
+// key用途, 与JWK的use一致, Use为空时可以同时用于签名和加密
+const (
+	KeyUseSig = "sig"
+	KeyUseEnc = "enc"
+)
+
+// Key 带key ID的密钥
+// RSA密钥使用Private/Public, 可以加密和签名; ECDSA、Ed25519使用SigningKey/VerifyKey, 只能签名
+// 私钥为空时只能加密和验签
+type Key struct {
+	ID         string
+	Active     bool
+	Use        string
+	Alg        SignAlgorithm
+	Private    *rsa.PrivateKey
+	Public     *rsa.PublicKey
+	SigningKey crypto.Signer
+	VerifyKey  crypto.PublicKey
+	CreatedAt  time.Time
+}
+
+// PublicKey 返回RSA公钥或VerifyKey
+func (k *Key) PublicKey() crypto.PublicKey {
+	if k.Public != nil {
+		return k.Public
+	}
+	return k.VerifyKey
+}
+
+// Algorithm Alg为空时按密钥类型使用DefaultAlgorithm
+func (k *Key) Algorithm() (SignAlgorithm, error) {
+	if k.Alg != "" {
+		return k.Alg, nil
+	}
+	return DefaultAlgorithm(k.PublicKey())
+}
+
+// allow Use为空或与use一致
+func (k *Key) allow(use string) error {
+	if k.Use != "" && k.Use != use {
+		return fmt.Errorf("%w: key %s is for %s", ErrKeyUse, k.ID, k.Use)
+	}
+	return nil
+}
+
+// Signer alg为空时使用Algorithm(), Use为enc时返回ErrKeyUse
+func (k *Key) Signer(alg SignAlgorithm) (Signer, error) {
+	if err := k.allow(KeyUseSig); err != nil {
+		return nil, err
+	}
+	if alg == "" {
+		var err error
+		if alg, err = k.Algorithm(); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case k.Private != nil:
+		return NewSigner(k.Private, alg)
+	case k.SigningKey != nil:
+		return NewSigner(k.SigningKey, alg)
+	default:
+		return nil, ErrNoPrivateKey
+	}
+}
+
+// Verifier alg为空时使用Algorithm(), Use为enc时返回ErrKeyUse
+func (k *Key) Verifier(alg SignAlgorithm) (Verifier, error) {
+	if err := k.allow(KeyUseSig); err != nil {
+		return nil, err
+	}
+	if alg == "" {
+		var err error
+		if alg, err = k.Algorithm(); err != nil {
+			return nil, err
+		}
+	}
+	return NewVerifier(k.PublicKey(), alg)
+}
+
+// KeyID 按RFC 7638计算公钥thumbprint作为默认key ID
+func KeyID(pub *rsa.PublicKey) string {
+	kid, _ := Thumbprint(pub)
+	return kid
+}
+
+// KeyRing 保存多把密钥, 新数据使用active密钥, 旧数据按密文中的key ID解密
+type KeyRing struct {
+	lock   sync.RWMutex
+	keys   map[string]*Key
+	active string
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: map[string]*Key{}}
+}
+
+// Add 添加密钥, ID为空时使用KeyID, Active为true时替换当前active密钥
+func (r *KeyRing) Add(key *Key) error {
+	if err := prepareKey(key); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.store(key)
+	return nil
+}
+
+// prepareKey 规范化并校验key, 补全ID和CreatedAt
+func prepareKey(key *Key) error {
+	if key == nil {
+		return errors.New("keyring: nil key")
+	}
+	// RSA密钥统一放到Private/Public
+	if priv, ok := key.SigningKey.(*rsa.PrivateKey); ok && key.Private == nil {
+		key.Private, key.SigningKey = priv, nil
+	}
+	if pub, ok := key.VerifyKey.(*rsa.PublicKey); ok && key.Public == nil {
+		key.Public, key.VerifyKey = pub, nil
+	}
+	if key.Public == nil && key.Private != nil {
+		key.Public = &key.Private.PublicKey
+	}
+	if key.VerifyKey == nil && key.SigningKey != nil {
+		key.VerifyKey = key.SigningKey.Public()
+	}
+	if key.PublicKey() == nil {
+		return errors.New("keyring: key has no public part")
+	}
+	if key.Use != "" && key.Use != KeyUseSig && key.Use != KeyUseEnc {
+		return fmt.Errorf("keyring: unknown key use %q", key.Use)
+	}
+	if key.Alg != "" {
+		if err := checkAlgorithm(key.PublicKey(), key.Alg); err != nil {
+			return err
+		}
+	}
+	if key.ID == "" {
+		kid, err := Thumbprint(key.PublicKey())
+		if err != nil {
+			return err
+		}
+		key.ID = kid
+	}
+	if len(key.ID) > 255 {
+		return errors.New("keyring: key id too long")
+	}
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+	return nil
+}
+
+// store 需持有写锁, 保存副本, 调用方之后修改key不影响密钥环
+func (r *KeyRing) store(key *Key) {
+	stored := *key
+	r.keys[key.ID] = &stored
+	if key.Active {
+		r.active = key.ID
+	} else if r.active == key.ID {
+		r.active = ""
+	}
+}
+
+// AddPEM 添加PEM格式的私钥或公钥, 支持RSA、ECDSA和Ed25519
+func (r *KeyRing) AddPEM(kid string, pemData []byte, active bool) error {
+	return r.AddPEMWithPassphrase(kid, pemData, nil, active)
+}
+
+// AddPEMWithPassphrase 添加加密的私钥PEM, 未加密时passphrase可以为空
+func (r *KeyRing) AddPEMWithPassphrase(kid string, pemData, passphrase []byte, active bool) error {
+	key := &Key{ID: kid, Active: active}
+	if priv, err := ParsePrivateKeyPEM(pemData, passphrase); err == nil {
+		key.SigningKey = priv
+	} else if pub, perr := ParsePublicKeyPEM(pemData); perr == nil {
+		key.VerifyKey = pub
+	} else {
+		return fmt.Errorf("keyring: parse key %s: %v", kid, err)
+	}
+	return r.Add(key)
+}
+
+// Get 按key ID查找, 返回副本
+func (r *KeyRing) Get(kid string) (*Key, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	key, ok := r.keys[kid]
+	if !ok {
+		return nil, false
+	}
+	return r.copyKey(key), true
+}
+
+// Active 返回当前用于加密和签名的密钥副本
+func (r *KeyRing) Active() (*Key, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	key, ok := r.keys[r.active]
+	if !ok {
+		return nil, ErrNoActiveKey
+	}
+	return r.copyKey(key), nil
+}
+
+// copyKey 需持有锁, Active按r.active计算
+func (r *KeyRing) copyKey(key *Key) *Key {
+	k := *key
+	k.Active = key.ID == r.active
+	return &k
+}
+
+// SetActive 切换active密钥, 旧密钥保留用于解密和验签
+func (r *KeyRing) SetActive(kid string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.keys[kid]; !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+	r.active = kid
+	return nil
+}
+
+// Remove 删除密钥, 删除后该密钥加密的数据将无法解密
+func (r *KeyRing) Remove(kid string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.keys, kid)
+	if r.active == kid {
+		r.active = ""
+	}
+}
+
+// Keys 按创建时间返回所有密钥的副本
+func (r *KeyRing) Keys() []*Key {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	keys := make([]*Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, r.copyKey(key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Rotate 生成新密钥并设为active, bits小于MinKeyBits时返回ErrKeySize
+func (r *KeyRing) Rotate(bits int) (*Key, error) {
+	generated, err := GenerateKeyPair(bits, nil)
+	if err != nil {
+		return nil, err
+	}
+	key := generated.Key(true)
+	if err := r.Add(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// privateKeys kid存在时只返回对应私钥, 旧格式(无kid)返回全部私钥, active优先; 跳过只用于签名的密钥
+func (r *KeyRing) privateKeys(kid string) []*rsa.PrivateKey {
+	if kid != "" {
+		if key, ok := r.Get(kid); ok && key.Private != nil && key.allow(KeyUseEnc) == nil {
+			return []*rsa.PrivateKey{key.Private}
+		}
+		return nil
+	}
+
+	r.lock.RLock()
+	active := r.active
+	r.lock.RUnlock()
+	var privs []*rsa.PrivateKey
+	for _, key := range r.Keys() {
+		if key.Private == nil || key.allow(KeyUseEnc) != nil {
+			continue
+		}
+		if key.ID == active {
+			privs = append([]*rsa.PrivateKey{key.Private}, privs...)
+		} else {
+			privs = append(privs, key.Private)
+		}
+	}
+	return privs
+}
+
+// EncryptStream 使用active密钥做信封加密, 密文携带key ID
+func (r *KeyRing) EncryptStream(dst io.Writer, src io.Reader) error {
+	key, err := r.Active()
+	if err != nil {
+		return err
+	}
+	if err := key.allow(KeyUseEnc); err != nil {
+		return err
+	}
+	if key.Public == nil {
+		return fmt.Errorf("keyring: active key %s is not rsa, cannot encrypt", key.ID)
+	}
+	return envelopeEncrypt(key.Public, key.ID, dst, src)
+}
+
+// DecryptStream 按密文中的key ID选择私钥解密
+func (r *KeyRing) DecryptStream(dst io.Writer, src io.Reader) error {
+	return envelopeDecrypt(r.privateKeys, dst, src)
+}
+
+func (r *KeyRing) Encrypt(plaintext []byte) ([]byte, error) {
+	var out bytes.Buffer
+	if err := r.EncryptStream(&out, bytes.NewReader(plaintext)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (r *KeyRing) Decrypt(ciphertext []byte) ([]byte, error) {
+	var out bytes.Buffer
+	if err := r.DecryptStream(&out, bytes.NewReader(ciphertext)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// EncryptBase64 与RsaBase64Encode相同的base64输出
+func (r *KeyRing) EncryptBase64(plaintext []byte) (string, error) {
+	data, err := r.Encrypt(plaintext)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecryptBase64 同时支持信封密文和PKCS#1 v1.5密文(依次尝试所有私钥)
+func (r *KeyRing) DecryptBase64(data string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	if IsEnvelope(raw) {
+		return r.Decrypt(raw)
+	}
+	for _, priv := range r.privateKeys("") {
+		if plain, err := rsa.DecryptPKCS1v15(rand.Reader, priv, raw); err == nil {
+			return plain, nil
+		}
+	}
+	return nil, ErrEnvelopeKey
+}
+
+// 签名格式, 所有整数为大端序
+//
+//	magic "LXSG" | version(1) | alg(1) | kidLen(1) | kid | signature
+var signatureMagic = []byte("LXSG")
+
+const signatureVersion1 byte = 1
+
+// 签名格式中alg字节与算法的对应关系, 只能追加不能修改
+var signatureAlgs = []SignAlgorithm{1: PS256, 2: PS384, 3: PS512, 4: RS256, 5: RS384, 6: RS512, 7: ES256, 8: ES384, 9: EdDSA}
+
+var ErrSignatureFormat = errors.New("signature: invalid format")
+
+// Sign 使用active密钥签名, 算法为key.Algorithm(), 签名携带key ID
+func (r *KeyRing) Sign(data []byte) ([]byte, error) {
+	return r.SignWith("", data)
+}
+
+// SignWith 使用active密钥和指定算法签名
+func (r *KeyRing) SignWith(alg SignAlgorithm, data []byte) ([]byte, error) {
+	key, err := r.Active()
+	if err != nil {
+		return nil, err
+	}
+	signer, err := key.Signer(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	algID := -1
+	for i, a := range signatureAlgs {
+		if a != "" && a == signer.Algorithm() {
+			algID = i
+		}
+	}
+	if algID < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, signer.Algorithm())
+	}
+
+	sig, err := signer.Sign(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(signatureMagic)+3+len(key.ID)+len(sig))
+	out = append(out, signatureMagic...)
+	out = append(out, signatureVersion1, byte(algID), byte(len(key.ID)))
+	out = append(out, key.ID...)
+	return append(out, sig...), nil
+}
+
+// Verify 按签名中的key ID查找公钥验签, 轮换后旧签名仍可验证
+// 密钥指定了Alg时, 签名中的算法必须与其一致
+func (r *KeyRing) Verify(data, signature []byte) error {
+	kid, alg, sig, err := parseSignature(signature)
+	if err != nil {
+		return err
+	}
+	key, ok := r.Get(kid)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+	if int(alg) >= len(signatureAlgs) || signatureAlgs[alg] == "" {
+		return fmt.Errorf("%w: unknown alg %d", ErrSignatureFormat, alg)
+	}
+	// alg来自签名本身, 密钥指定了Alg时必须一致, 与jwts的keyFunc相同
+	if key.Alg != "" && key.Alg != signatureAlgs[alg] {
+		return fmt.Errorf("%w: %s for key %s", ErrUnsupportedAlgorithm, signatureAlgs[alg], kid)
+	}
+	verifier, err := key.Verifier(signatureAlgs[alg])
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(data, sig)
+}
+
+// SignatureKeyID 返回签名中的key ID
+func SignatureKeyID(signature []byte) (string, error) {
+	kid, _, _, err := parseSignature(signature)
+	return kid, err
+}
+
+func parseSignature(signature []byte) (kid string, alg byte, sig []byte, err error) {
+	head := len(signatureMagic) + 3
+	if len(signature) < head || !bytes.HasPrefix(signature, signatureMagic) {
+		return "", 0, nil, ErrSignatureFormat
+	}
+	if signature[len(signatureMagic)] != signatureVersion1 {
+		return "", 0, nil, fmt.Errorf("%w: unsupported version %d", ErrSignatureFormat, signature[len(signatureMagic)])
+	}
+	alg = signature[len(signatureMagic)+1]
+	kidLen := int(signature[len(signatureMagic)+2])
+	if len(signature) < head+kidLen {
+		return "", 0, nil, ErrSignatureFormat
+	}
+	return string(signature[head : head+kidLen]), alg, signature[head+kidLen:], nil
+}
+
+// LoadKeyRingFromDir 读取目录下所有 *.pem, 文件名(不含后缀)为key ID
+// active为空时使用目录下 active 文件的内容, 仍为空则不设置active
+func LoadKeyRingFromDir(dir, active string) (*KeyRing, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewKeyRing()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		kid := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if err := r.AddPEM(kid, data, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if active == "" {
+		if data, err := os.ReadFile(filepath.Join(dir, "active")); err == nil {
+			active = strings.TrimSpace(string(data))
+		}
+	}
+	if active != "" {
+		if err := r.SetActive(active); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// LoadKeyRingFromEnv 读取 <prefix>_<KID>=PEM 形式的环境变量, <prefix>_ACTIVE 指定active key ID
+// PEM内容可以直接写入, 也可以base64编码后写入
+func LoadKeyRingFromEnv(prefix string) (*KeyRing, error) {
+	prefix = strings.TrimSuffix(prefix, "_") + "_"
+	activeEnv := prefix + "ACTIVE"
+
+	r := NewKeyRing()
+	for _, env := range os.Environ() {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || name == activeEnv {
+			continue
+		}
+		kid := strings.ToLower(strings.TrimPrefix(name, prefix))
+		if err := r.AddPEM(kid, envPEM(value), false); err != nil {
+			return nil, err
+		}
+	}
+
+	if active := strings.ToLower(os.Getenv(activeEnv)); active != "" {
+		if err := r.SetActive(active); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func envPEM(value string) []byte {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value)); err == nil {
+		return decoded
+	}
+	return []byte(value)
+}
+
+// LoadKeyRingFromSecret 读取Kubernetes Secret, data中 <kid>.pem 为密钥, active 为active key ID
+func LoadKeyRingFromSecret(namespace, name string) (*KeyRing, error) {
+	return LoadKeyRingFromSecretWithPassphrase(namespace, name, nil)
+}
+
+// LoadKeyRingFromSecretWithPassphrase 读取GeneratedKey.SaveSecret保存的加密私钥
+func LoadKeyRingFromSecretWithPassphrase(namespace, name string, passphrase []byte) (*KeyRing, error) {
+	client, err := clientgo.InitClientE()
+	if err != nil {
+		return nil, err
+	}
+	return LoadKeyRingFromSecretClient(context.Background(), client, namespace, name, passphrase)
+}
+
+// LoadKeyRingFromSecretClient 使用指定的client读取Secret
+func LoadKeyRingFromSecretClient(ctx context.Context, client kubernetes.Interface, namespace, name string, passphrase []byte) (*KeyRing, error) {
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	r := NewKeyRing()
+	for item, data := range secret.Data {
+		if !strings.HasSuffix(item, ".pem") {
+			continue
+		}
+		if err := r.AddPEMWithPassphrase(strings.TrimSuffix(item, ".pem"), data, passphrase, false); err != nil {
+			return nil, err
+		}
+	}
+
+	if active := strings.TrimSpace(string(secret.Data["active"])); active != "" {
+		if err := r.SetActive(active); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,325 @@
+package rsa
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PEM block类型, 解析时不依赖类型, 只在输出时使用标准类型
+const (
+	PEMTypeRSAPrivateKey       = "RSA PRIVATE KEY"
+	PEMTypePrivateKey          = "PRIVATE KEY"
+	PEMTypeEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+	PEMTypePublicKey           = "PUBLIC KEY"
+	PEMTypeCertificate         = "CERTIFICATE"
+)
+
+var (
+	ErrPEM                = errors.New("pem decode failed")
+	ErrPassphraseRequired = errors.New("private key is encrypted, passphrase required")
+	ErrKeyType            = errors.New("unsupported key type")
+)
+
+// ParsePrivateKeyPEM 解析PKCS#1、PKCS#8、SEC1(EC)格式的私钥, 不校验PEM类型
+// 支持 Proc-Type 加密的传统PEM 和 PBES2 加密的PKCS#8, 未加密时passphrase可以为空
+func ParsePrivateKeyPEM(pemData []byte, passphrase []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, ErrPEM
+	}
+
+	der := block.Bytes
+	switch {
+	case block.Type == PEMTypeEncryptedPrivateKey:
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		var err error
+		if der, err = decryptPKCS8(der, passphrase); err != nil {
+			return nil, err
+		}
+	// openssl传统加密PEM(Proc-Type: 4,ENCRYPTED)
+	case x509.IsEncryptedPEMBlock(block):
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		var err error
+		if der, err = x509.DecryptPEMBlock(block, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	return parsePrivateKeyDER(der)
+}
+
+func parsePrivateKeyDER(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case *ecdsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		default:
+			return nil, fmt.Errorf("%w: %T", ErrKeyType, key)
+		}
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("private key is not PKCS#1, PKCS#8 or SEC1")
+}
+
+// ParsePublicKeyPEM 解析PKIX、PKCS#1公钥或证书中的公钥, 不校验PEM类型
+func ParsePublicKeyPEM(pemData []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, ErrPEM
+	}
+
+	if key, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		return cert.PublicKey, nil
+	}
+	return nil, errors.New("public key is not PKIX, PKCS#1 or certificate")
+}
+
+// MarshalPrivateKeyPEM 输出PKCS#8私钥, passphrase不为空时使用PBES2(PBKDF2-SHA256 + AES-256-CBC)加密
+func MarshalPrivateKeyPEM(key crypto.PrivateKey, passphrase []byte) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(der)
+
+	if len(passphrase) == 0 {
+		return pem.EncodeToMemory(&pem.Block{Type: PEMTypePrivateKey, Bytes: der}), nil
+	}
+
+	encrypted, err := encryptPKCS8(der, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypeEncryptedPrivateKey, Bytes: encrypted}), nil
+}
+
+// MarshalPublicKeyPEM 输出PKIX公钥
+func MarshalPublicKeyPEM(key crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypePublicKey, Bytes: der}), nil
+}
+
+// GenerateSigningKey 按签名算法生成密钥, RS*/PS* 生成2048位RSA
+func GenerateSigningKey(alg SignAlgorithm) (crypto.Signer, error) {
+	switch alg {
+	case RS256, RS384, RS512, PS256, PS384, PS512:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case ES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case ES384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case EdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+	}
+}
+
+// PKCS#8 PBES2, 参考 RFC 8018
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+const pbkdf2Iterations = 600000
+
+// maxPBKDF2Iterations 解密时允许的迭代次数上限, 避免构造的密钥文件耗尽CPU
+const maxPBKDF2Iterations = 10 * pbkdf2Iterations
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Data      []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+func decryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("%w: pkcs8 encryption %v", ErrKeyType, info.Algorithm.Algorithm)
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("%w: kdf %v", ErrKeyType, params.KeyDerivationFunc.Algorithm)
+	}
+
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, err
+	}
+
+	if kdf.Iterations < 1 || kdf.Iterations > maxPBKDF2Iterations {
+		return nil, fmt.Errorf("pkcs8: pbkdf2 iterations %d out of range", kdf.Iterations)
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA512):
+		prf = sha512.New
+	default:
+		return nil, fmt.Errorf("%w: prf %v", ErrKeyType, kdf.PRF.Algorithm)
+	}
+
+	var keyLen int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLen = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keyLen = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("%w: cipher %v", ErrKeyType, params.EncryptionScheme.Algorithm)
+	}
+
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2.Key(passphrase, kdf.Salt, kdf.Iterations, keyLen, prf)
+	defer zero(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(info.Data)%block.BlockSize() != 0 || len(info.Data) == 0 {
+		return nil, errors.New("pkcs8: invalid ciphertext")
+	}
+
+	plain := make([]byte, len(info.Data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, info.Data)
+
+	// PKCS#7 padding, 口令错误时通常在这里失败
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > block.BlockSize() {
+		return nil, errors.New("pkcs8: decryption failed, wrong passphrase?")
+	}
+	for _, b := range plain[len(plain)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("pkcs8: decryption failed, wrong passphrase?")
+		}
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+func encryptPKCS8(der, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key := pbkdf2.Key(passphrase, salt, pbkdf2Iterations, 32, sha256.New)
+	defer zero(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	pad := aes.BlockSize - len(der)%aes.BlockSize
+	plain := make([]byte, len(der)+pad)
+	copy(plain, der)
+	for i := len(der); i < len(plain); i++ {
+		plain[i] = byte(pad)
+	}
+	defer zero(plain)
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, plain)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: pbkdf2Iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParams, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		Data:      encrypted,
+	})
+}
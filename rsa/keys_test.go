@@ -0,0 +1,141 @@
+package rsa
+
+import (
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func Test_ParsePrivateKeyFormats(t *testing.T) {
+	want, err := ParsePrivateKey(string(readTestdata(t, "openssl_rsa.pem")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		file       string
+		passphrase []byte
+	}{
+		{"openssl_rsa_pkcs8.pem", nil},
+		{"openssl_rsa_pkcs8_enc.pem", []byte("lflxp")},
+		{"openssl_rsa_enc.pem", []byte("lflxp")},
+	}
+	for _, c := range cases {
+		got, err := ParsePrivateKeyWithPassphrase(string(readTestdata(t, c.file)), c.passphrase)
+		if err != nil {
+			t.Fatalf("%s: %v", c.file, err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("%s: key mismatch", c.file)
+		}
+	}
+
+	if _, err := ParsePrivateKey(string(readTestdata(t, "openssl_rsa_pkcs8_enc.pem"))); !errors.Is(err, ErrPassphraseRequired) {
+		t.Fatalf("expected ErrPassphraseRequired, got %v", err)
+	}
+	if _, err := ParsePrivateKeyWithPassphrase(string(readTestdata(t, "openssl_rsa_pkcs8_enc.pem")), []byte("wrong")); err == nil {
+		t.Fatal("expected error for wrong passphrase")
+	}
+	if _, err := ParsePrivateKey(string(readTestdata(t, "openssl_ed25519.pem"))); !errors.Is(err, ErrKeyType) {
+		t.Fatalf("expected ErrKeyType, got %v", err)
+	}
+}
+
+func Test_DecryptPKCS8Iterations(t *testing.T) {
+	for _, iterations := range []int{0, maxPBKDF2Iterations + 1, math.MaxInt32} {
+		kdfParams, _ := asn1.Marshal(pbkdf2Params{Salt: make([]byte, 16), Iterations: iterations})
+		ivParams, _ := asn1.Marshal(make([]byte, 16))
+		params, _ := asn1.Marshal(pbes2Params{
+			KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+			EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+		})
+		der, _ := asn1.Marshal(encryptedPrivateKeyInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+			Data:      make([]byte, 32),
+		})
+		if _, err := decryptPKCS8(der, []byte("lflxp")); err == nil || !strings.Contains(err.Error(), "iterations") {
+			t.Fatalf("%d: expected iterations error, got %v", iterations, err)
+		}
+	}
+}
+
+type privateKeyEqual interface {
+	Equal(x crypto.PrivateKey) bool
+}
+
+func Test_MarshalPrivateKeyPEM(t *testing.T) {
+	for _, alg := range []SignAlgorithm{PS256, ES256, ES384, EdDSA} {
+		key, err := GenerateSigningKey(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, passphrase := range [][]byte{nil, []byte("lflxp")} {
+			data, err := MarshalPrivateKeyPEM(key, passphrase)
+			if err != nil {
+				t.Fatalf("%s: %v", alg, err)
+			}
+			got, err := ParsePrivateKeyPEM(data, passphrase)
+			if err != nil {
+				t.Fatalf("%s: %v", alg, err)
+			}
+			if !got.(privateKeyEqual).Equal(key) {
+				t.Fatalf("%s: key mismatch", alg)
+			}
+		}
+	}
+}
+
+func Test_SignerOpenssl(t *testing.T) {
+	message := readTestdata(t, "message.txt")
+
+	cases := []struct {
+		key, pub, sig string
+		alg           SignAlgorithm
+	}{
+		{"openssl_ec256.pem", "openssl_ec256_pub.pem", "message.es256.sig", ES256},
+		{"openssl_ec384.pem", "openssl_ec384_pub.pem", "message.es384.sig", ES384},
+		{"openssl_ed25519.pem", "openssl_ed25519_pub.pem", "message.eddsa.sig", EdDSA},
+		{"openssl_rsa_pkcs8.pem", "openssl_rsa_pub.pem", "message.ps256.sig", PS256},
+	}
+	for _, c := range cases {
+		verifier, err := ParseVerifierPEM(readTestdata(t, c.pub), "")
+		if err != nil {
+			t.Fatalf("%s: %v", c.pub, err)
+		}
+		if verifier.Algorithm() != c.alg {
+			t.Fatalf("%s: expected %s, got %s", c.pub, c.alg, verifier.Algorithm())
+		}
+		if err := verifier.Verify(message, readTestdata(t, c.sig)); err != nil {
+			t.Fatalf("%s: %v", c.sig, err)
+		}
+
+		signer, err := ParseSignerPEM(readTestdata(t, c.key), nil, c.alg)
+		if err != nil {
+			t.Fatalf("%s: %v", c.key, err)
+		}
+		sig, err := signer.Sign(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifier.Verify(message, sig); err != nil {
+			t.Fatalf("%s: %v", c.key, err)
+		}
+		if err := signer.Verifier().Verify([]byte("other"), sig); err == nil {
+			t.Fatalf("%s: expected error for modified data", c.key)
+		}
+	}
+}
+
+func Test_SignerAlgorithmMismatch(t *testing.T) {
+	ec, _ := GenerateSigningKey(ES256)
+	if _, err := NewSigner(ec, ES384); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatalf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+	if _, err := NewVerifier(ec.Public(), PS256); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Fatalf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+package rsa
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/httpclient"
+)
+
+// DecryptedFieldsKey gin context中保存解密后明文的key, 值为map[字段]明文, 请求结束后清零
+const DecryptedFieldsKey = "rsa_decrypted_fields"
+
+// maxDecryptBody 需要解密的请求体大小上限, 超过时响应413
+const maxDecryptBody = 10 << 20
+
+var errDecryptBodyTooLarge = errors.New("request body too large")
+
+// DecryptFunc 解密单个字段, 输入为请求中的密文字符串
+type DecryptFunc func(ciphertext string) ([]byte, error)
+
+// DecryptFields 使用包级私钥解密请求体中的字段, 兼容PKCS#1 v1.5和信封加密
+// json请求按路径查找, 如 password、user.password、items.*.secret, 表单请求按字段名查找
+// 字段不存在时跳过, 解密失败时响应FailedDecodeError, 错误详情只记录日志
+func DecryptFields(fields ...string) gin.HandlerFunc {
+	return DecryptFieldsWith(RsaBase64UrlDecode, fields...)
+}
+
+// DecryptFieldsWithKeyRing 使用KeyRing按密文中的key ID解密
+func DecryptFieldsWithKeyRing(ring *KeyRing, fields ...string) gin.HandlerFunc {
+	return DecryptFieldsWith(ring.DecryptBase64, fields...)
+}
+
+// DecryptFieldsWith 使用自定义解密函数, 替换后的请求体和明文在handler结束后清零
+// 注意: 绑定到结构体中的string无法清零, 敏感数据请从DecryptedField获取[]byte
+func DecryptFieldsWith(decrypt DecryptFunc, fields ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		d := &fieldDecrypter{decrypt: decrypt, plain: map[string][]byte{}}
+		var (
+			body []byte
+			err  error
+		)
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		switch mediaType {
+		case gin.MIMEJSON:
+			body, err = d.json(c.Request, fields)
+		case gin.MIMEPOSTForm:
+			body, err = d.form(c.Request, fields)
+		case gin.MIMEMultipartPOSTForm:
+			err = d.multipart(c.Request, fields)
+		}
+		defer d.zero(body)
+
+		if err != nil {
+			logger.WarnContext(c.Request.Context(), "decrypt request fields", "path", c.Request.URL.Path, "error", err)
+			if errors.Is(err, errDecryptBodyTooLarge) {
+				httpclient.SendErrorMessage(c, http.StatusRequestEntityTooLarge, httpclient.FailedParamsError, err.Error())
+			} else {
+				httpclient.SendErrorMessage(c, http.StatusBadRequest, httpclient.FailedDecodeError, "")
+			}
+			c.Abort()
+			return
+		}
+		if body != nil {
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
+		}
+		c.Set(DecryptedFieldsKey, d.plain)
+		c.Next()
+	}
+}
+
+// DecryptedField 获取中间件解密后的明文, handler结束后会被清零, 不要保留引用
+func DecryptedField(c *gin.Context, field string) ([]byte, bool) {
+	v, ok := c.Get(DecryptedFieldsKey)
+	if !ok {
+		return nil, false
+	}
+	plain, ok := v.(map[string][]byte)[field]
+	return plain, ok
+}
+
+type fieldDecrypter struct {
+	decrypt DecryptFunc
+	plain   map[string][]byte
+}
+
+func (d *fieldDecrypter) field(name, ciphertext string) (string, error) {
+	plain, err := d.decrypt(normalizeBase64(ciphertext))
+	if err != nil {
+		return "", fmt.Errorf("decrypt field %s failed: %w", name, err)
+	}
+	d.plain[name] = plain
+	return string(plain), nil
+}
+
+func (d *fieldDecrypter) zero(body []byte) {
+	for i := range body {
+		body[i] = 0
+	}
+	for _, plain := range d.plain {
+		for i := range plain {
+			plain[i] = 0
+		}
+	}
+}
+
+func (d *fieldDecrypter) json(req *http.Request, fields []string) ([]byte, error) {
+	raw, err := readDecryptBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return raw, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		if doc, err = d.jsonPath(doc, strings.Split(field, "."), field); err != nil {
+			return nil, err
+		}
+	}
+	for i := range raw {
+		raw[i] = 0
+	}
+	return json.Marshal(doc)
+}
+
+// readDecryptBody 多读一个字节判断是否超过maxDecryptBody, 避免截断后解析不完整的请求体
+func readDecryptBody(req *http.Request) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(req.Body, maxDecryptBody+1))
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxDecryptBody {
+		for i := range raw {
+			raw[i] = 0
+		}
+		return nil, errDecryptBodyTooLarge
+	}
+	return raw, nil
+}
+
+// jsonPath 按路径替换字段, *匹配数组的所有元素
+func (d *fieldDecrypter) jsonPath(node interface{}, path []string, name string) (interface{}, error) {
+	if len(path) == 0 {
+		s, ok := node.(string)
+		if !ok {
+			return nil, fmt.Errorf("decrypt field %s failed: not a string", name)
+		}
+		return d.field(name, s)
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[path[0]]
+		if !ok || child == nil {
+			return n, nil
+		}
+		v, err := d.jsonPath(child, path[1:], name)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = v
+		return n, nil
+	case []interface{}:
+		for i := range n {
+			if path[0] != "*" && path[0] != strconv.Itoa(i) {
+				continue
+			}
+			v, err := d.jsonPath(n[i], path[1:], name+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			n[i] = v
+		}
+		return n, nil
+	}
+	return node, nil
+}
+
+func (d *fieldDecrypter) form(req *http.Request, fields []string) ([]byte, error) {
+	raw, err := readDecryptBody(req)
+	if err != nil {
+		return nil, err
+	}
+	values, err := url.ParseQuery(string(raw))
+	for i := range raw {
+		raw[i] = 0
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := d.values(values, fields); err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+// multipart 先解析表单再替换, gin绑定时不会重复解析
+func (d *fieldDecrypter) multipart(req *http.Request, fields []string) error {
+	if err := req.ParseMultipartForm(32 << 20); err != nil {
+		return err
+	}
+	if err := d.values(req.MultipartForm.Value, fields); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if v, ok := req.MultipartForm.Value[field]; ok {
+			req.PostForm[field] = v
+			req.Form[field] = v
+		}
+	}
+	return nil
+}
+
+func (d *fieldDecrypter) values(values url.Values, fields []string) error {
+	for _, field := range fields {
+		for i, v := range values[field] {
+			plain, err := d.field(field, v)
+			if err != nil {
+				return err
+			}
+			values[field][i] = plain
+		}
+	}
+	return nil
+}
+
+// normalizeBase64 兼容表单中+被解码为空格, 以及url安全的base64
+func normalizeBase64(s string) string {
+	s = strings.ReplaceAll(s, " ", "+")
+	s = strings.NewReplacer("-", "+", "_", "/").Replace(s)
+	if m := len(s) % 4; m != 0 {
+		s += strings.Repeat("=", 4-m)
+	}
+	return s
+}
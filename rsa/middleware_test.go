@@ -0,0 +1,100 @@
+package rsa
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/httpclient"
+)
+
+func Test_DecryptFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ring := NewKeyRing()
+	if err := ring.Add(&Key{ID: "k1", Private: newTestKey(t), Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	encrypt := func(s string) string {
+		out, err := ring.EncryptBase64([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	var plain []byte
+	router := gin.New()
+	router.POST("/login", DecryptFieldsWithKeyRing(ring, "password", "users.*.token"), func(c *gin.Context) {
+		var req struct {
+			Username string `json:"username" form:"username"`
+			Password string `json:"password" form:"password"`
+			Users    []struct {
+				Token string `json:"token"`
+			} `json:"users"`
+		}
+		if err := c.ShouldBind(&req); err != nil {
+			httpclient.SendErrorMessage(c, http.StatusBadRequest, httpclient.FailedParamsError, err.Error())
+			return
+		}
+		plain, _ = DecryptedField(c, "password")
+		tokens := []string{}
+		for _, u := range req.Users {
+			tokens = append(tokens, u.Token)
+		}
+		httpclient.SendSuccessMessage(c, http.StatusOK, req.Username+":"+req.Password+":"+strings.Join(tokens, ","))
+	})
+
+	do := func(contentType, body string) (int, httpclient.Result) {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var result httpclient.Result
+		_ = json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"username": "admin",
+		"password": encrypt("secret"),
+		"users":    []map[string]string{{"token": encrypt("t1")}, {"token": encrypt("t2")}},
+	})
+	if code, result := do("application/json; charset=utf-8", string(body)); code != http.StatusOK || result.Data != "admin:secret:t1,t2" {
+		t.Fatalf("json: unexpected %d %+v", code, result)
+	}
+	if string(plain) != strings.Repeat("\x00", len("secret")) {
+		t.Fatalf("plaintext not zeroed: %q", plain)
+	}
+
+	// 表单中未转义的+会被解码为空格
+	form := "username=admin&password=" + strings.ReplaceAll(encrypt("secret"), "+", " ")
+	if code, result := do("application/x-www-form-urlencoded", form); code != http.StatusOK || result.Data != "admin:secret:" {
+		t.Fatalf("form: unexpected %d %+v", code, result)
+	}
+
+	for _, body := range []string{
+		`{"password":"not-encrypted"}`,
+		`{"password":123}`,
+		"password=" + url.QueryEscape("!!!"),
+	} {
+		contentType := "application/json"
+		if !strings.HasPrefix(body, "{") {
+			contentType = "application/x-www-form-urlencoded"
+		}
+		// 解密和解析错误不返回给客户端
+		if code, result := do(contentType, body); code != http.StatusBadRequest || result.ErrorCode != httpclient.FailedDecodeError || result.ErrorMessage != "解码失败" {
+			t.Fatalf("%s: unexpected %d %+v", body, code, result)
+		}
+	}
+
+	// 超过上限时不截断, 直接拒绝
+	large := `{"username":"` + strings.Repeat("a", maxDecryptBody) + `"}`
+	if code, _ := do("application/json", large); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("large body: unexpected %d", code)
+	}
+}
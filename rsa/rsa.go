@@ -3,15 +3,14 @@ package rsa
 import (
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/pem"
-	"errors"
 	"fmt"
-	"os"
 
+	"github.com/lflxp/tools/logging"
 	"github.com/lflxp/tools/utils"
 )
 
+var logger = logging.For("rsa")
+
 var (
 	PrivateKey string
 	PublicKey  string
@@ -19,97 +18,127 @@ var (
 
 // 加密RSA
 func RsaEncrypt(origData []byte) ([]byte, error) {
-	//解密pem格式的公钥
-	block, _ := pem.Decode([]byte(PublicKey))
-	if block == nil {
-		return nil, errors.New("public key error")
-	}
-	// 解析公钥
-	pubInterface, err := x509.ParsePKIXPublicKey(block.Bytes)
+	pub, err := ParsePublicKey(PublicKey)
 	if err != nil {
 		return nil, err
 	}
-	// 类型断言
-	pub := pubInterface.(*rsa.PublicKey)
 	//加密
 	return rsa.EncryptPKCS1v15(rand.Reader, pub, origData)
 }
 
+// RsaBase64Encode 超过PKCS#1 v1.5长度限制(密钥长度-11)时自动改用信封加密
 func RsaBase64Encode(origData string) string {
-	rsa, _ := RsaEncrypt([]byte(origData))
-	return utils.EncodeBase64(string(rsa[:]))
+	pub, err := ParsePublicKey(PublicKey)
+	if err != nil {
+		logger.Error("RsaBase64Encode", "error", err)
+		return ""
+	}
+
+	var data []byte
+	if len(origData) > pub.Size()-11 {
+		data, err = EnvelopeEncrypt(pub, []byte(origData))
+	} else {
+		data, err = rsa.EncryptPKCS1v15(rand.Reader, pub, []byte(origData))
+	}
+	if err != nil {
+		logger.Error("RsaBase64Encode", "error", err)
+		return ""
+	}
+	return utils.EncodeBase64(string(data))
 }
+
+// RsaBase64UrlDecode 同时支持PKCS#1 v1.5密文和信封加密密文
 func RsaBase64UrlDecode(origData string) ([]byte, error) {
 	//origDataUrlDecode, _ := url.QueryUnescape(origData)
-	base64, _ := utils.DecodeBase64(origData)
+	base64, err := utils.DecodeBase64(origData)
+	if err != nil {
+		return nil, err
+	}
+	if IsEnvelope([]byte(base64)) {
+		priv, err := ParsePrivateKey(PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return EnvelopeDecrypt(priv, []byte(base64))
+	}
 	return RsaDecrypt([]byte(base64))
 }
 
+// EnvelopeBase64Encode 使用包级公钥做信封加密并base64编码
+func EnvelopeBase64Encode(origData []byte) (string, error) {
+	pub, err := ParsePublicKey(PublicKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := EnvelopeEncrypt(pub, origData)
+	if err != nil {
+		return "", err
+	}
+	return utils.EncodeBase64(string(data)), nil
+}
+
+// ParsePublicKey 解析PEM格式的RSA公钥, 支持PKIX、PKCS#1和证书
+func ParsePublicKey(pemData string) (*rsa.PublicKey, error) {
+	key, err := ParsePublicKeyPEM([]byte(pemData))
+	if err != nil {
+		return nil, fmt.Errorf("public key error: %w", err)
+	}
+	// 类型断言
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: public key is %T, not rsa", ErrKeyType, key)
+	}
+	return pub, nil
+}
+
+// ParsePrivateKey 解析PEM格式的RSA私钥, 支持PKCS#1和PKCS#8, 不校验PEM类型
+func ParsePrivateKey(pemData string) (*rsa.PrivateKey, error) {
+	return ParsePrivateKeyWithPassphrase(pemData, nil)
+}
+
+// ParsePrivateKeyWithPassphrase 解析加密的PEM格式RSA私钥
+func ParsePrivateKeyWithPassphrase(pemData string, passphrase []byte) (*rsa.PrivateKey, error) {
+	key, err := ParsePrivateKeyPEM([]byte(pemData), passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("private key error: %w", err)
+	}
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: private key is %T, not rsa", ErrKeyType, key)
+	}
+	return priv, nil
+}
+
 // 解密RSA
 func RsaDecrypt(ciphertext []byte) ([]byte, error) {
-	//解密
-	block, _ := pem.Decode([]byte(PrivateKey))
-	if block == nil {
-		return nil, errors.New("private key error!")
-	}
-	//解析PKCS1格式的私钥
-	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	priv, err := ParsePrivateKey(PrivateKey)
 	if err != nil {
 		return nil, err
 	}
 	// 解密
 	data, err := rsa.DecryptPKCS1v15(rand.Reader, priv, ciphertext)
 	if err != nil {
-		fmt.Println(err.Error())
+		logger.Debug("rsa decrypt", "error", err)
 		return nil, err
 	}
 	return data, nil
 }
 
-// 生成RSA私钥和公钥，保存到文件中
+// GenerateRSAKey 生成RSA密钥写入当前目录的private.pem(0600)和public.pem, 并设置包级密钥
+// 为兼容旧调用方不限制bits, 小于MinKeyBits时只记录警告; GenerateKeyPair会返回ErrKeySize
+// Deprecated: 使用GenerateKeyPair, 按需调用WriteFiles、SaveSecret或SaveDB
 func GenerateRSAKey(bits int) error {
-	//GenerateKey函数使用随机数据生成器random生成一对具有指定字位数的RSA密钥
-	//Reader是一个全局、共享的密码用强随机数生成器
-	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
-	if err != nil {
-		return err
+	if bits < MinKeyBits {
+		logger.Warn("rsa key size below minimum", "bits", bits, "min", MinKeyBits)
 	}
-	//保存私钥
-	//通过x509标准将得到的ras私钥序列化为ASN.1 的 DER编码字符串
-	X509PrivateKey := x509.MarshalPKCS1PrivateKey(privateKey)
-	//使用pem格式对x509输出的内容进行编码
-	//创建文件保存私钥
-	///Users/libin/lbdata
-	privateFile, err := os.Create("./private.pem")
+	key, err := generateKeyPair(bits, nil)
 	if err != nil {
 		return err
 	}
-	defer privateFile.Close()
-	//构建一个pem.Block结构体对象
-	privateBlock := pem.Block{Type: "RSA Private Key", Bytes: X509PrivateKey}
-	//将数据保存到文件
-	pem.Encode(privateFile, &privateBlock)
-	PrivateKey = string(pem.EncodeToMemory(&privateBlock))
-
-	//保存公钥
-	//获取公钥的数据
-	publicKey := privateKey.PublicKey
-	//X509对公钥编码
-	X509PublicKey, err := x509.MarshalPKIXPublicKey(&publicKey)
-	if err != nil {
-		return err
-	}
-	//pem格式编码
-	//创建用于保存公钥的文件
-	publicFile, err := os.Create("./public.pem")
-	if err != nil {
+	if err := key.WriteFiles("."); err != nil {
 		return err
 	}
-	defer publicFile.Close()
-	//创建一个pem.Block结构体对象
-	publicBlock := pem.Block{Type: "RSA Public Key", Bytes: X509PublicKey}
-	//保存到文件
-	pem.Encode(publicFile, &publicBlock)
-	PublicKey = string(pem.EncodeToMemory(&publicBlock))
+	PrivateKey = string(key.PrivatePEM)
+	PublicKey = string(key.PublicPEM)
 	return nil
 }
@@ -1 +1,228 @@
 package rsa
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+)
+
+func newTestKey(t testing.TB) *rsa.PrivateKey {
+	testKeyOnce.Do(func() {
+		var err error
+		testKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	return testKey
+}
+
+func Test_Envelope(t *testing.T) {
+	key := newTestKey(t)
+
+	for _, size := range []int{0, 1, 245, 4096, envelopeChunkSize, envelopeChunkSize + 1, 3*envelopeChunkSize - 7} {
+		plaintext := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+			t.Fatal(err)
+		}
+
+		ciphertext, err := EnvelopeEncrypt(&key.PublicKey, plaintext)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !IsEnvelope(ciphertext) {
+			t.Fatalf("size %d: missing envelope header", size)
+		}
+
+		got, err := EnvelopeDecrypt(key, ciphertext)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("size %d: plaintext mismatch", size)
+		}
+	}
+}
+
+func Test_EnvelopeTamper(t *testing.T) {
+	key := newTestKey(t)
+	plaintext := bytes.Repeat([]byte("lflxp"), envelopeChunkSize/2)
+
+	ciphertext, err := EnvelopeEncrypt(&key.PublicKey, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flipped := append([]byte{}, ciphertext...)
+	flipped[len(flipped)-1] ^= 0xff
+	if _, err := EnvelopeDecrypt(key, flipped); err == nil {
+		t.Fatal("expected error for modified ciphertext")
+	}
+
+	// 截掉最后一块
+	headerLen := len(envelopeMagic) + 1 + 4 + 2 + key.Size() + envelopeNoncePrefix
+	truncated := ciphertext[:headerLen+envelopeChunkSize+16]
+	if _, err := EnvelopeDecrypt(key, truncated); err == nil {
+		t.Fatal("expected error for truncated ciphertext")
+	}
+
+	version := append([]byte{}, ciphertext...)
+	version[len(envelopeMagic)] = 99
+	if _, err := EnvelopeDecrypt(key, version); !errors.Is(err, ErrEnvelopeVersion) {
+		t.Fatalf("expected ErrEnvelopeVersion, got %v", err)
+	}
+}
+
+func Test_RsaBase64Envelope(t *testing.T) {
+	key := newTestKey(t)
+	pubDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	PublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+
+	for _, origData := range []string{"password", strings.Repeat("x", 1024)} {
+		encoded := RsaBase64Encode(origData)
+		if encoded == "" {
+			t.Fatalf("len %d: encode failed", len(origData))
+		}
+		decoded, err := RsaBase64UrlDecode(encoded)
+		if err != nil {
+			t.Fatalf("len %d: %v", len(origData), err)
+		}
+		if string(decoded) != origData {
+			t.Fatalf("len %d: plaintext mismatch", len(origData))
+		}
+	}
+}
+
+func Test_KeyRingRotate(t *testing.T) {
+	ring := NewKeyRing()
+	if err := ring.Add(&Key{ID: "old", Private: newTestKey(t), Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext, err := ring.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := ring.Sign([]byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid, _ := EnvelopeKeyID(ciphertext); kid != "old" {
+		t.Fatalf("expected kid old, got %q", kid)
+	}
+
+	rotated, err := ring.Rotate(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := ring.Active(); active.ID != rotated.ID {
+		t.Fatalf("expected active %s, got %s", rotated.ID, active.ID)
+	}
+	// Get返回副本, 修改不影响密钥环
+	if old, _ := ring.Get("old"); old.Active {
+		t.Fatal("old key should not be active")
+	} else {
+		old.Active, old.Private = true, nil
+	}
+	if old, _ := ring.Get("old"); old.Private == nil {
+		t.Fatal("keyring modified through returned key")
+	}
+
+	plain, err := ring.Decrypt(ciphertext)
+	if err != nil || string(plain) != "secret" {
+		t.Fatalf("decrypt after rotate: %q %v", plain, err)
+	}
+	if err := ring.Verify([]byte("payload"), signature); err != nil {
+		t.Fatalf("verify after rotate: %v", err)
+	}
+	if err := ring.Verify([]byte("tampered"), signature); err == nil {
+		t.Fatal("expected verify error for tampered payload")
+	}
+
+	ring.Remove("old")
+	if _, err := ring.Decrypt(ciphertext); !errors.Is(err, ErrEnvelopeKey) {
+		t.Fatalf("expected ErrEnvelopeKey, got %v", err)
+	}
+}
+
+func Test_KeyRingUse(t *testing.T) {
+	if _, err := NewKeyRing().Rotate(1024); !errors.Is(err, ErrKeySize) {
+		t.Fatalf("expected ErrKeySize, got %v", err)
+	}
+
+	priv := newTestKey(t)
+	ring := NewKeyRing()
+	if err := ring.Add(&Key{ID: "enc", Use: KeyUseEnc, Private: priv, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Add(&Key{ID: "x", Use: "both", Private: priv}); err == nil {
+		t.Fatal("expected error for unknown use")
+	}
+	ciphertext, err := ring.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Sign([]byte("payload")); !errors.Is(err, ErrKeyUse) {
+		t.Fatalf("expected ErrKeyUse for sign, got %v", err)
+	}
+
+	// 只用于签名的密钥不能加密和解密
+	if err := ring.Add(&Key{ID: "enc", Use: KeyUseSig, Private: priv, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ring.Encrypt([]byte("secret")); !errors.Is(err, ErrKeyUse) {
+		t.Fatalf("expected ErrKeyUse for encrypt, got %v", err)
+	}
+	if _, err := ring.Decrypt(ciphertext); err == nil {
+		t.Fatal("expected decrypt error for signing key")
+	}
+	signature, err := ring.Sign([]byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ring.Verify([]byte("payload"), signature); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_LoadKeyRingFromSecretClient(t *testing.T) {
+	key, err := MarshalPrivateKeyPEM(newTestKey(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := fake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "keys"},
+		Data:       map[string][]byte{"k1.pem": key, "active": []byte("k1")},
+	})
+	ring, err := LoadKeyRingFromSecretClient(context.Background(), client, "default", "keys", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active, err := ring.Active(); err != nil || active.ID != "k1" {
+		t.Fatalf("unexpected active %v %v", active, err)
+	}
+	if _, err := LoadKeyRingFromSecretClient(context.Background(), client, "default", "missing", nil); err == nil {
+		t.Fatal("expected not found")
+	}
+}
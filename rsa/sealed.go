@@ -0,0 +1,228 @@
+package rsa
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/yaml"
+
+	"github.com/lflxp/tools/sdk/clientgo"
+)
+
+// SealedSecret 的apiVersion和kind, 只在git中保存, 不会提交到集群
+const (
+	SealedSecretAPIVersion = "tools.lflxp.io/v1alpha1"
+	SealedSecretKind       = "SealedSecret"
+)
+
+var ErrSealedSecret = errors.New("invalid sealed secret")
+
+// SealedSecret 加密后的Secret清单, 每个value单独做信封加密
+// 明文前缀绑定 namespace/name/key, 密文不能被挪到其他Secret或key下使用
+type SealedSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              SealedSecretSpec `json:"spec"`
+}
+
+type SealedSecretSpec struct {
+	// Template 解密后Secret的type、labels、annotations
+	Template      SealedSecretTemplate `json:"template,omitempty"`
+	EncryptedData map[string]string    `json:"encryptedData"`
+}
+
+type SealedSecretTemplate struct {
+	Type        corev1.SecretType `json:"type,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func sealScope(namespace, name, key string) []byte {
+	return []byte(namespace + "/" + name + "/" + key + "\x00")
+}
+
+// SealSecret 使用KeyRing的active公钥加密Secret清单, stringData会合并到data
+// 集群外只需要公钥: ring.AddPEM(kid, pubPEM, true)
+func SealSecret(manifest []byte, ring *KeyRing) ([]byte, error) {
+	secret := &corev1.Secret{}
+	if err := yaml.UnmarshalStrict(manifest, secret); err != nil {
+		return nil, err
+	}
+	if secret.Kind != "Secret" {
+		return nil, fmt.Errorf("%w: kind %q is not Secret", ErrSealedSecret, secret.Kind)
+	}
+	if secret.Name == "" {
+		return nil, fmt.Errorf("%w: metadata.name is required", ErrSealedSecret)
+	}
+	if secret.Namespace == "" {
+		secret.Namespace = metav1.NamespaceDefault
+	}
+
+	data := map[string][]byte{}
+	for k, v := range secret.Data {
+		data[k] = v
+	}
+	for k, v := range secret.StringData {
+		data[k] = []byte(v)
+	}
+
+	sealed := &SealedSecret{
+		TypeMeta: metav1.TypeMeta{APIVersion: SealedSecretAPIVersion, Kind: SealedSecretKind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secret.Name,
+			Namespace: secret.Namespace,
+		},
+		Spec: SealedSecretSpec{
+			Template: SealedSecretTemplate{
+				Type:        secret.Type,
+				Labels:      secret.Labels,
+				Annotations: secret.Annotations,
+			},
+			EncryptedData: map[string]string{},
+		},
+	}
+	for k, v := range data {
+		plain := append(sealScope(secret.Namespace, secret.Name, k), v...)
+		ciphertext, err := ring.Encrypt(plain)
+		zero(plain)
+		if err != nil {
+			return nil, fmt.Errorf("seal %s: %w", k, err)
+		}
+		sealed.Spec.EncryptedData[k] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+	return yaml.Marshal(sealed)
+}
+
+// UnsealSecret 按密文中的key ID解密, 还原为Secret
+func UnsealSecret(sealedManifest []byte, ring *KeyRing) (*corev1.Secret, error) {
+	sealed, err := parseSealedSecret(sealedManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        sealed.Name,
+			Namespace:   sealed.Namespace,
+			Labels:      sealed.Spec.Template.Labels,
+			Annotations: sealed.Spec.Template.Annotations,
+		},
+		Type: sealed.Spec.Template.Type,
+		Data: map[string][]byte{},
+	}
+	for k, v := range sealed.Spec.EncryptedData {
+		ciphertext, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("unseal %s: %w", k, err)
+		}
+		plain, err := ring.Decrypt(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("unseal %s: %w", k, err)
+		}
+		scope := sealScope(sealed.Namespace, sealed.Name, k)
+		if !bytes.HasPrefix(plain, scope) {
+			zero(plain)
+			return nil, fmt.Errorf("%w: %s was sealed for another secret", ErrSealedSecret, k)
+		}
+		secret.Data[k] = plain[len(scope):]
+	}
+	return secret, nil
+}
+
+// ResealSecret 使用当前active密钥重新加密, 用于密钥轮换后更新git中的清单
+func ResealSecret(sealedManifest []byte, ring *KeyRing) ([]byte, error) {
+	secret, err := UnsealSecret(sealedManifest, ring)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroSecret(secret)
+	manifest, err := yaml.Marshal(secret)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(manifest)
+	return SealSecret(manifest, ring)
+}
+
+// SealedSecretKeyIDs 返回清单中使用的key ID, 用于判断是否需要ResealSecret
+func SealedSecretKeyIDs(sealedManifest []byte) ([]string, error) {
+	sealed, err := parseSealedSecret(sealedManifest)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for k, v := range sealed.Spec.EncryptedData {
+		ciphertext, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		kid, err := EnvelopeKeyID(ciphertext)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+		ids[kid] = true
+	}
+	result := make([]string, 0, len(ids))
+	for kid := range ids {
+		result = append(result, kid)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// ApplySealedSecret 解密后通过clientgo.InstallYaml创建或更新Secret, 无法连接集群时返回错误
+func ApplySealedSecret(sealedManifest []byte, ring *KeyRing) error {
+	data, err := unsealManifest(sealedManifest, ring)
+	if err != nil {
+		return err
+	}
+	defer zero(data)
+	return clientgo.InstallYaml(data)
+}
+
+// ApplySealedSecretClient 同ApplySealedSecret, 使用指定的RESTMapper和dynamic client
+func ApplySealedSecretClient(ctx context.Context, mapper meta.RESTMapper, dyn dynamic.Interface, sealedManifest []byte, ring *KeyRing) error {
+	data, err := unsealManifest(sealedManifest, ring)
+	if err != nil {
+		return err
+	}
+	defer zero(data)
+	return clientgo.InstallYamlClient(ctx, mapper, dyn, data)
+}
+
+// unsealManifest 解密为Secret清单(json), 用完后由调用方清零
+func unsealManifest(sealedManifest []byte, ring *KeyRing) ([]byte, error) {
+	secret, err := UnsealSecret(sealedManifest, ring)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroSecret(secret)
+	return json.Marshal(secret)
+}
+
+func parseSealedSecret(manifest []byte) (*SealedSecret, error) {
+	sealed := &SealedSecret{}
+	if err := yaml.UnmarshalStrict(manifest, sealed); err != nil {
+		return nil, err
+	}
+	if sealed.APIVersion != SealedSecretAPIVersion || sealed.Kind != SealedSecretKind {
+		return nil, fmt.Errorf("%w: %s %s", ErrSealedSecret, sealed.APIVersion, sealed.Kind)
+	}
+	return sealed, nil
+}
+
+func zeroSecret(secret *corev1.Secret) {
+	for _, v := range secret.Data {
+		zero(v)
+	}
+}
@@ -0,0 +1,105 @@
+package rsa
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testSecretManifest = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+  labels:
+    app: db
+type: Opaque
+data:
+  password: c2VjcmV0
+stringData:
+  user: admin
+`
+
+func Test_SealSecret(t *testing.T) {
+	ring := NewKeyRing()
+	if err := ring.Add(&Key{ID: "k1", Private: newTestKey(t), Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 集群外只使用公钥加密
+	pubPEM, _ := MarshalPublicKeyPEM(&newTestKey(t).PublicKey)
+	public := NewKeyRing()
+	if err := public.AddPEM("k1", pubPEM, true); err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := SealSecret([]byte(testSecretManifest), public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sealed), "c2VjcmV0") || strings.Contains(string(sealed), "admin") {
+		t.Fatalf("plaintext leaked:\n%s", sealed)
+	}
+
+	secret, err := UnsealSecret(sealed, ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["password"]) != "secret" || string(secret.Data["user"]) != "admin" || secret.Labels["app"] != "db" {
+		t.Fatalf("unexpected secret %+v", secret)
+	}
+
+	// 密文不能挪到其他Secret
+	moved := strings.Replace(string(sealed), "name: db", "name: other", 1)
+	if _, err := UnsealSecret([]byte(moved), ring); !errors.Is(err, ErrSealedSecret) {
+		t.Fatalf("expected ErrSealedSecret, got %v", err)
+	}
+
+	// 轮换后重新加密
+	priv2, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if err := ring.Add(&Key{ID: "k2", Private: priv2, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+	resealed, err := ResealSecret(sealed, ring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := SealedSecretKeyIDs(resealed); err != nil || len(ids) != 1 || ids[0] != "k2" {
+		t.Fatalf("unexpected key ids %v %v", ids, err)
+	}
+	ring.Remove("k1")
+	if _, err := UnsealSecret(resealed, ring); err != nil {
+		t.Fatal(err)
+	}
+
+	// 与clientgo.InstallYaml相同, 使用server-side apply
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Secret"), meta.RESTScopeNamespace)
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	var patch k8stesting.PatchAction
+	dyn.PrependReactor("patch", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch = action.(k8stesting.PatchAction)
+		return true, &unstructured.Unstructured{}, nil
+	})
+	if err := ApplySealedSecretClient(context.Background(), mapper, dyn, resealed, ring); err != nil {
+		t.Fatal(err)
+	}
+	if patch == nil || patch.GetPatchType() != types.ApplyPatchType || patch.GetNamespace() != "prod" || patch.GetName() != "db" {
+		t.Fatalf("unexpected patch %+v", patch)
+	}
+	applied := &corev1.Secret{}
+	if err := json.Unmarshal(patch.GetPatch(), applied); err != nil || string(applied.Data["password"]) != "secret" || applied.Labels["app"] != "db" {
+		t.Fatalf("unexpected applied secret %+v %v", applied, err)
+	}
+}
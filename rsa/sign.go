@@ -0,0 +1,155 @@
+package rsa
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"errors"
+	"fmt"
+)
+
+// SignAlgorithm 签名算法, 命名与JWA(RFC 7518)一致
+type SignAlgorithm string
+
+const (
+	RS256 SignAlgorithm = "RS256"
+	RS384 SignAlgorithm = "RS384"
+	RS512 SignAlgorithm = "RS512"
+	PS256 SignAlgorithm = "PS256"
+	PS384 SignAlgorithm = "PS384"
+	PS512 SignAlgorithm = "PS512"
+	ES256 SignAlgorithm = "ES256"
+	ES384 SignAlgorithm = "ES384"
+	EdDSA SignAlgorithm = "EdDSA"
+)
+
+var ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+
+func (alg SignAlgorithm) hash() (crypto.Hash, bool, error) {
+	switch alg {
+	case RS256:
+		return crypto.SHA256, false, nil
+	case RS384:
+		return crypto.SHA384, false, nil
+	case RS512:
+		return crypto.SHA512, false, nil
+	case PS256:
+		return crypto.SHA256, true, nil
+	case PS384:
+		return crypto.SHA384, true, nil
+	case PS512:
+		return crypto.SHA512, true, nil
+	default:
+		return 0, false, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+	}
+}
+
+// Sign 使用RSA-PSS或PKCS#1 v1.5对data签名
+// PSS的salt长度等于hash长度, 与JWS和openssl rsa_pss_saltlen:digest一致
+func Sign(priv *rsa.PrivateKey, alg SignAlgorithm, data []byte) ([]byte, error) {
+	hash, pss, err := alg.hash()
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	if pss {
+		return rsa.SignPSS(rand.Reader, priv, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	}
+	return rsa.SignPKCS1v15(rand.Reader, priv, hash, digest)
+}
+
+// Verify 验证Sign的签名, PSS自动识别salt长度以兼容其他实现
+func Verify(pub *rsa.PublicKey, alg SignAlgorithm, data, sig []byte) error {
+	hash, pss, err := alg.hash()
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	if pss {
+		return rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto})
+	}
+	return rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+}
+
+// RsaSign 使用包级私钥签名
+func RsaSign(alg SignAlgorithm, data []byte) ([]byte, error) {
+	priv, err := ParsePrivateKey(PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return Sign(priv, alg, data)
+}
+
+// RsaVerify 使用包级公钥验签
+func RsaVerify(alg SignAlgorithm, data, sig []byte) error {
+	pub, err := ParsePublicKey(PublicKey)
+	if err != nil {
+		return err
+	}
+	return Verify(pub, alg, data, sig)
+}
+
+// OAEPOptions OAEP参数, Hash同时用于MGF1, 为0时默认SHA-256
+type OAEPOptions struct {
+	Hash  crypto.Hash
+	Label []byte
+}
+
+func (o *OAEPOptions) params() (crypto.Hash, []byte, error) {
+	if o == nil {
+		return crypto.SHA256, nil, nil
+	}
+	hash := o.Hash
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+	if !hash.Available() {
+		return 0, nil, fmt.Errorf("%w: hash %v", ErrUnsupportedAlgorithm, hash)
+	}
+	return hash, o.Label, nil
+}
+
+// EncryptOAEP RSA-OAEP加密, 数据长度上限为 密钥长度-2*hash长度-2
+func EncryptOAEP(pub *rsa.PublicKey, plaintext []byte, opts *OAEPOptions) ([]byte, error) {
+	hash, label, err := opts.params()
+	if err != nil {
+		return nil, err
+	}
+	return rsa.EncryptOAEP(hash.New(), rand.Reader, pub, plaintext, label)
+}
+
+// DecryptOAEP RSA-OAEP解密, opts需与加密时一致
+func DecryptOAEP(priv *rsa.PrivateKey, ciphertext []byte, opts *OAEPOptions) ([]byte, error) {
+	hash, label, err := opts.params()
+	if err != nil {
+		return nil, err
+	}
+	return rsa.DecryptOAEP(hash.New(), rand.Reader, priv, ciphertext, label)
+}
+
+// RsaEncryptOAEP 使用包级公钥做OAEP加密
+func RsaEncryptOAEP(origData []byte, opts *OAEPOptions) ([]byte, error) {
+	pub, err := ParsePublicKey(PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptOAEP(pub, origData, opts)
+}
+
+// RsaDecryptOAEP 使用包级私钥做OAEP解密
+func RsaDecryptOAEP(ciphertext []byte, opts *OAEPOptions) ([]byte, error) {
+	priv, err := ParsePrivateKey(PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return DecryptOAEP(priv, ciphertext, opts)
+}
@@ -0,0 +1,175 @@
+package rsa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+)
+
+// Signer RSA、ECDSA、Ed25519签名密钥的统一接口
+// ECDSA签名为ASN.1 DER编码, 与openssl dgst -sign输出一致
+type Signer interface {
+	Algorithm() SignAlgorithm
+	Sign(data []byte) ([]byte, error)
+	Verifier() Verifier
+}
+
+// Verifier 验签接口, 可以只持有公钥
+type Verifier interface {
+	Algorithm() SignAlgorithm
+	Verify(data, sig []byte) error
+}
+
+var ErrVerification = errors.New("signature verification failed")
+
+// DefaultAlgorithm 按密钥类型返回默认算法: RSA=PS256, P-256=ES256, P-384=ES384, Ed25519=EdDSA
+func DefaultAlgorithm(key crypto.PublicKey) (SignAlgorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return PS256, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return ES256, nil
+		case elliptic.P384():
+			return ES384, nil
+		}
+	case ed25519.PublicKey:
+		return EdDSA, nil
+	}
+	return "", fmt.Errorf("%w: %T", ErrKeyType, key)
+}
+
+// checkAlgorithm 校验算法与密钥类型是否匹配
+func checkAlgorithm(key crypto.PublicKey, alg SignAlgorithm) error {
+	ok := false
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		_, _, err := alg.hash()
+		ok = err == nil
+	case *ecdsa.PublicKey:
+		ok = (alg == ES256 && k.Curve == elliptic.P256()) || (alg == ES384 && k.Curve == elliptic.P384())
+	case ed25519.PublicKey:
+		ok = alg == EdDSA
+	default:
+		return fmt.Errorf("%w: %T", ErrKeyType, key)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s for %T", ErrUnsupportedAlgorithm, alg, key)
+	}
+	return nil
+}
+
+// NewSigner alg为空时使用DefaultAlgorithm
+func NewSigner(key crypto.Signer, alg SignAlgorithm) (Signer, error) {
+	if alg == "" {
+		var err error
+		if alg, err = DefaultAlgorithm(key.Public()); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkAlgorithm(key.Public(), alg); err != nil {
+		return nil, err
+	}
+	return &keySigner{alg: alg, key: key}, nil
+}
+
+// NewVerifier alg为空时使用DefaultAlgorithm
+func NewVerifier(key crypto.PublicKey, alg SignAlgorithm) (Verifier, error) {
+	if alg == "" {
+		var err error
+		if alg, err = DefaultAlgorithm(key); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkAlgorithm(key, alg); err != nil {
+		return nil, err
+	}
+	return &keyVerifier{alg: alg, key: key}, nil
+}
+
+// ParseSignerPEM 解析任意格式的私钥PEM并创建Signer
+func ParseSignerPEM(pemData, passphrase []byte, alg SignAlgorithm) (Signer, error) {
+	key, err := ParsePrivateKeyPEM(pemData, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigner(key, alg)
+}
+
+// ParseVerifierPEM 解析任意格式的公钥PEM或证书并创建Verifier
+func ParseVerifierPEM(pemData []byte, alg SignAlgorithm) (Verifier, error) {
+	key, err := ParsePublicKeyPEM(pemData)
+	if err != nil {
+		return nil, err
+	}
+	return NewVerifier(key, alg)
+}
+
+type keySigner struct {
+	alg SignAlgorithm
+	key crypto.Signer
+}
+
+func (s *keySigner) Algorithm() SignAlgorithm {
+	return s.alg
+}
+
+func (s *keySigner) Sign(data []byte) ([]byte, error) {
+	switch k := s.key.(type) {
+	case *rsa.PrivateKey:
+		return Sign(k, s.alg, data)
+	case *ecdsa.PrivateKey:
+		return ecdsa.SignASN1(rand.Reader, k, ecdsaDigest(s.alg, data))
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, data), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrKeyType, s.key)
+	}
+}
+
+func (s *keySigner) Verifier() Verifier {
+	return &keyVerifier{alg: s.alg, key: s.key.Public()}
+}
+
+type keyVerifier struct {
+	alg SignAlgorithm
+	key crypto.PublicKey
+}
+
+func (v *keyVerifier) Algorithm() SignAlgorithm {
+	return v.alg
+}
+
+func (v *keyVerifier) Verify(data, sig []byte) error {
+	switch k := v.key.(type) {
+	case *rsa.PublicKey:
+		return Verify(k, v.alg, data, sig)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, ecdsaDigest(v.alg, data), sig) {
+			return ErrVerification
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, data, sig) {
+			return ErrVerification
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %T", ErrKeyType, v.key)
+	}
+}
+
+func ecdsaDigest(alg SignAlgorithm, data []byte) []byte {
+	h := crypto.SHA256.New()
+	if alg == ES384 {
+		h = crypto.SHA384.New()
+	}
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/httpclient"
+)
+
+// 错误码注册表与httpclient共用, 任一包注册的错误码两边都可以使用
+
+type (
+	Severity  = httpclient.Severity
+	ErrorCode = httpclient.ErrorCode
+	CodeError = httpclient.CodeError
+)
+
+const (
+	SeverityInfo    = httpclient.SeverityInfo
+	SeverityWarning = httpclient.SeverityWarning
+	SeverityError   = httpclient.SeverityError
+)
+
+const (
+	ShowTypeSilent       = httpclient.ShowTypeSilent
+	ShowTypeWarn         = httpclient.ShowTypeWarn
+	ShowTypeError        = httpclient.ShowTypeError
+	ShowTypeNotification = httpclient.ShowTypeNotification
+	ShowTypeRedirect     = httpclient.ShowTypeRedirect
+)
+
+const (
+	LanguageZh = httpclient.LanguageZh
+	LanguageEn = httpclient.LanguageEn
+)
+
+// RegisterErrorCode 见httpclient.RegisterErrorCode
+func RegisterErrorCode(ec ErrorCode) {
+	httpclient.RegisterErrorCode(ec)
+}
+
+// LookupErrorCode 见httpclient.LookupErrorCode
+func LookupErrorCode(code string) (*ErrorCode, bool) {
+	return httpclient.LookupErrorCode(code)
+}
+
+// AcceptLanguages 见httpclient.AcceptLanguages
+func AcceptLanguages(c *gin.Context) []string {
+	return httpclient.AcceptLanguages(c)
+}
+
+// NewError 见httpclient.NewError
+func NewError(code string) error {
+	return httpclient.NewError(code)
+}
+
+// Errorf 见httpclient.Errorf
+func Errorf(code, format string, args ...interface{}) error {
+	return httpclient.Errorf(code, format, args...)
+}
+
+// WithCode 见httpclient.WithCode
+func WithCode(err error, code string) error {
+	return httpclient.WithCode(err, code)
+}
+
+// ErrorCodeOf 见httpclient.ErrorCodeOf
+func ErrorCodeOf(err error) string {
+	return httpclient.ErrorCodeOf(err)
+}
+
+// SendError 见httpclient.SendError
+func SendError(c *gin.Context, err error) {
+	httpclient.SendError(c, err)
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/httpclient"
+)
+
+// 响应格式协商与httpclient共用同一实现
+const (
+	FormatJSON   = httpclient.FormatJSON
+	FormatYAML   = httpclient.FormatYAML
+	FormatCSV    = httpclient.FormatCSV
+	FormatNDJSON = httpclient.FormatNDJSON
+
+	ParameterFormat = httpclient.ParameterFormat
+
+	MIMEYAML   = httpclient.MIMEYAML
+	MIMECSV    = httpclient.MIMECSV
+	MIMENDJSON = httpclient.MIMENDJSON
+)
+
+// NegotiateFormat 见httpclient.NegotiateFormat
+func NegotiateFormat(c *gin.Context) string {
+	return httpclient.NegotiateFormat(c)
+}
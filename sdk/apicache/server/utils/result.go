@@ -1,9 +1,9 @@
 package utils
 
 import (
-	"encoding/json"
-
 	"github.com/gin-gonic/gin"
+
+	"github.com/lflxp/tools/httpclient"
 )
 
 // request fail
@@ -19,6 +19,7 @@ const (
 	LicenseError             = "4008"
 	BiddenError              = "4009"
 	RoleChangeNeedReLogin    = "4010"
+	ResourceConflict         = "4011"
 )
 
 // system error
@@ -26,73 +27,21 @@ const (
 	SystemError = "9000"
 )
 
-var responseMap = map[string]string{
-	Failed:                   "操作失败!",
-	FailedParamsError:        "参数错误!",
-	FailedUnknown:            "操作失败，未知错误!",
-	FailedRemoteServiceError: "调用第三方接口异常!",
-	SystemError:              "系统异常!",
-	FailedDecodeError:        "解码失败",
-	AthorizationError:        "Header Not Contains Authorization",
-	JsonError:                "json序列化错误",
-}
-
-type Result struct {
-	Success      bool        `json:"success"`
-	Data         interface{} `json:"data"`
-	ErrorCode    string      `json:"errorCode"`
-	ErrorMessage string      `json:"errorMessage"`
-	Host         string      `json:"host"`
-	TraceId      string      `json:"traceid"`
-	ShowType     string      `json:"showtype"`
-}
-
-func (r *Result) String() string {
-	str, err := json.Marshal(r)
-	if err != nil {
-		return err.Error()
-	}
-	return string(str)
-}
+// Result 与httpclient.Result相同
+type Result = httpclient.Result
 
 func SendMessage(c *gin.Context, code int, success bool, data interface{}, errcode, errmsg, host, traceid, showtype string) {
-	info := &Result{
-		Success:      success,
-		Data:         data,
-		ErrorCode:    errcode,
-		ErrorMessage: errmsg,
-		Host:         host,
-		TraceId:      traceid,
-		ShowType:     showtype,
-	}
-	c.JSONP(code, info)
+	httpclient.SendMessage(c, code, success, data, errcode, errmsg, host, traceid, showtype)
 }
 
 // SendSuccessMessage select success and have data
+// 支持 ?format=yaml|csv|ndjson 或 Accept header 协商输出格式
 func SendSuccessMessage(c *gin.Context, code int, data interface{}) {
-	info := &Result{
-		Success: true,
-		Data:    data,
-		Host:    c.Request.URL.Path,
-	}
-
-	c.JSONP(code, info)
+	httpclient.SendSuccessMessage(c, code, data)
 }
 
 // SendErrorMessage select fail
+// errorMsg为空时使用错误码注册表中当前语言的文案
 func SendErrorMessage(c *gin.Context, code int, errorCode string, errorMsg string) {
-	if errorMsg == "" {
-		errorMsg = responseMap[errorCode]
-	}
-
-	info := &Result{
-		Success:      false,
-		Data:         nil,
-		ErrorCode:    errorCode,
-		ErrorMessage: errorMsg,
-		Host:         c.Request.URL.Path,
-	}
-
-	c.Writer.Header().Del("Content-Encoding")
-	c.JSONP(code, info)
+	httpclient.SendErrorMessage(c, code, errorCode, errorMsg)
 }
@@ -1,8 +1,6 @@
 package clientgo
 
 import (
-	"log/slog"
-
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
@@ -16,35 +14,48 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
+
+	"github.com/lflxp/tools/logging"
 )
 
+var logger = logging.For("sdk/clientgo")
+
 var (
 	once            sync.Once
 	twice           sync.Once
 	three           sync.Once
 	clients         dynamic.Interface
 	clientset       *kubernetes.Clientset
+	clientsetErr    error
+	clientsErr      error
 	discoveryclient *discovery.DiscoveryClient
+	discoveryErr    error
 	restConfig      *rest.Config
 )
 
 func InitClientDiscovery() *discovery.DiscoveryClient {
-	var err error
+	dc, err := InitClientDiscoveryE()
+	if err != nil {
+		// log.Fatal(err)
+		panic(err)
+	}
+	return dc
+}
+
+// InitClientDiscoveryE 同InitClientDiscovery, 无法连接集群时返回错误而不是panic
+func InitClientDiscoveryE() (*discovery.DiscoveryClient, error) {
 	// 实现同时集群内外的支持
 	// 便于本地调试
 	three.Do(func() {
-		slog.Info("start doInitDiscovery()")
+		logger.Info("init discovery client")
+		var err error
 		discoveryclient, err = doInitDiscovery()
 		if err != nil {
-			slog.Debug("init out of cluster error: %v", err)
-			discoveryclient, err = doInitDiscoveryInner()
-			if err != nil {
-				// log.Fatal(err)
-				panic(err)
-			}
+			logger.Debug("init out of cluster", "error", err)
+			discoveryclient, discoveryErr = doInitDiscoveryInner()
 		}
 	})
-	return discoveryclient
+	return discoveryclient, discoveryErr
 }
 
 func doInitDiscovery() (*discovery.DiscoveryClient, error) {
@@ -87,40 +98,46 @@ func doInitDiscoveryInner() (*discovery.DiscoveryClient, error) {
 }
 
 func InitClient() *kubernetes.Clientset {
-	var err error
+	client, err := InitClientE()
+	if err != nil {
+		// log.Fatal(err)
+		panic(err)
+	}
+	return client
+}
+
+// InitClientE 同InitClient, 没有kubeconfig且不在集群内时返回错误而不是panic
+func InitClientE() (*kubernetes.Clientset, error) {
 	// 实现同时集群内外的支持
 	// 便于本地调试
 	twice.Do(func() {
-		slog.Info("start doInit()")
+		logger.Info("init kubernetes client")
+		var err error
 		clientset, err = doInit()
 		if err != nil {
-			slog.Debug("init out of cluster error", "Error", err)
-			clientset, err = doInitInner()
-			if err != nil {
-				// log.Fatal(err)
-				panic(err)
-			}
+			logger.Debug("init out of cluster", "error", err)
+			clientset, clientsetErr = doInitInner()
 		}
 	})
-	return clientset
+	return clientset, clientsetErr
 }
 
 func InitClientDynamic() (dynamic.Interface, error) {
-	var err error
 	// 实现同时集群内外的支持
 	// 便于本地调试
 	once.Do(func() {
-		slog.Info("start doInit()")
+		logger.Info("init dynamic client")
+		var err error
 		clients, err = DoInitDynamic()
 		if err != nil {
-			slog.Debug("init out of cluster error", "Error", err.Error())
-			clients, err = doInitInnerDynamic()
-			if err != nil {
-				slog.Error(err.Error())
+			logger.Debug("init out of cluster", "error", err)
+			clients, clientsErr = doInitInnerDynamic()
+			if clientsErr != nil {
+				logger.Error("init in cluster dynamic client", "error", clientsErr)
 			}
 		}
 	})
-	return clients, nil
+	return clients, clientsErr
 }
 
 // 集群内client-go
@@ -2,7 +2,6 @@ package clientgo
 
 import (
 	"flag"
-	"log/slog"
 	"path/filepath"
 	"sync"
 
@@ -22,8 +21,8 @@ func InitClientMetrics() (*versioned.Clientset, error) {
 	onceMetrics.Do(func() {
 		clientsMetrics, err = doMetricsInit()
 		if err != nil {
+			logger.Debug("init out of cluster metrics client", "error", err)
 			clientsMetrics, err = doMetricsInnerInit()
-			slog.Debug("init out of cluster", "Error", err.Error())
 			if err != nil {
 				panic(err)
 			}
@@ -3,8 +3,8 @@ package model
 import (
 	"context"
 	"errors"
-	"log/slog"
 
+	"github.com/lflxp/tools/logging"
 	"github.com/lflxp/tools/sdk/clientgo"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -13,6 +13,8 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 )
 
+var logger = logging.For("sdk/clientgo/model")
+
 type GetGVR struct {
 	Group     string                     `json:"group"`
 	Version   string                     `json:"version"`
@@ -111,7 +113,7 @@ func (g *GetGVR) Post() (*unstructured.Unstructured, error) {
 
 func (g *GetGVR) Patch() (*unstructured.Unstructured, error) {
 	if g.PatchData == "" {
-		slog.Error("PatchData is empty")
+		logger.Error("PatchData is empty")
 		return nil, errors.New("PatchData is empty")
 	}
 
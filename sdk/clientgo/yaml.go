@@ -3,9 +3,7 @@ package clientgo
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
-	"log/slog"
 	"os"
 
 	"go/ast"
@@ -25,10 +23,11 @@ import (
 )
 
 func InstallYaml(dataRaw []byte) error {
-	var decUnstructured = yaml2.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
-
 	// 1. Prepare a RESTMapper to find GVR
-	dc := InitClientDiscovery()
+	dc, err := InitClientDiscoveryE()
+	if err != nil {
+		return err
+	}
 	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
 
 	// 2. Prepare the dynamic client
@@ -36,6 +35,12 @@ func InstallYaml(dataRaw []byte) error {
 	if err != nil {
 		return err
 	}
+	return InstallYamlClient(context.Background(), mapper, dyn, dataRaw)
+}
+
+// InstallYamlClient 同InstallYaml, 使用指定的RESTMapper和dynamic client
+func InstallYamlClient(ctx context.Context, mapper meta.RESTMapper, dyn dynamic.Interface, dataRaw []byte) error {
+	var decUnstructured = yaml2.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
 
 	// 3. Decode YAML manifest into unstructured.Unstructured
 	obj := &unstructured.Unstructured{}
@@ -69,7 +74,7 @@ func InstallYaml(dataRaw []byte) error {
 	// 7. Create or Update the object with SSA
 	//     types.ApplyPatchType indicates SSA.
 	//     FieldManager specifies the field owner ID.
-	_, err = dr.Patch(context.Background(), obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+	_, err = dr.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
 		FieldManager: "lflxp-k8s-controller",
 	})
 
@@ -123,10 +128,10 @@ func UnInstallYaml(dataRaw []byte) error {
 
 func InstallYamlFilename(clientSet *kubernetes.Clientset, dynamicClient dynamic.Interface, ns string, filename string) error {
 	f, err := os.Open(filename)
-	slog.Info("=====================>" + filename)
+	logger.Info("install yaml", "file", filename)
 
 	if err != nil {
-		slog.Error(err.Error())
+		logger.Error("install yaml", "file", filename, "error", err)
 		return err
 	}
 	d := yaml.NewYAMLOrJSONDecoder(f, 4096)
@@ -134,7 +139,7 @@ func InstallYamlFilename(clientSet *kubernetes.Clientset, dynamicClient dynamic.
 
 	restMapperRes, err := restmapper.GetAPIGroupResources(dc)
 	if err != nil {
-		slog.Error(err.Error())
+		logger.Error("install yaml", "file", filename, "error", err)
 		return err
 	}
 
@@ -147,30 +152,29 @@ func InstallYamlFilename(clientSet *kubernetes.Clientset, dynamicClient dynamic.
 			if err == io.EOF {
 				break
 			}
-			slog.Error(err.Error())
+			logger.Error("install yaml", "file", filename, "error", err)
 		}
 
 		// runtime.Object
 		obj, gvk, err := unstructured.UnstructuredJSONScheme.Decode(ext.Raw, nil, nil)
 		if err != nil {
-			slog.Error(err.Error())
+			logger.Error("install yaml", "file", filename, "error", err)
 			return err
 		}
 
 		mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
-		fmt.Printf("mapping:%+v\n", mapping)
+		logger.Debug("rest mapping", "file", filename, "mapping", mapping)
 		if err != nil {
-			slog.Error(err.Error())
+			logger.Error("install yaml", "file", filename, "error", err)
 			return err
 		}
 
 		// runtime.Object转换为unstructed
 		unstructuredObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
 		if err != nil {
-			slog.Error(err.Error())
+			logger.Error("install yaml", "file", filename, "error", err)
 			return err
 		}
-		// fmt.Printf("unstructuredObj: %+v", unstructuredObj)
 
 		var unstruct unstructured.Unstructured
 
@@ -179,7 +183,7 @@ func InstallYamlFilename(clientSet *kubernetes.Clientset, dynamicClient dynamic.
 		if ns == "" {
 			res, err := dynamicClient.Resource(mapping.Resource).Create(context.TODO(), &unstruct, metav1.CreateOptions{})
 			if err != nil {
-				slog.Error(err.Error())
+				logger.Error("install yaml", "file", filename, "error", err)
 				return err
 			}
 
@@ -187,7 +191,7 @@ func InstallYamlFilename(clientSet *kubernetes.Clientset, dynamicClient dynamic.
 		} else {
 			res, err := dynamicClient.Resource(mapping.Resource).Namespace(ns).Create(context.TODO(), &unstruct, metav1.CreateOptions{})
 			if err != nil {
-				slog.Error(err.Error())
+				logger.Error("install yaml", "file", filename, "error", err)
 				return err
 			}
 			GuessType(res)
@@ -207,7 +211,7 @@ func UninstallYaml(clientSet *kubernetes.Clientset, dynamicClient dynamic.Interf
 	f, err := os.Open(filename)
 
 	if err != nil {
-		slog.Error(err.Error())
+		logger.Error("uninstall yaml", "file", filename, "error", err)
 		return err
 	}
 	d := yaml.NewYAMLOrJSONDecoder(f, 4096)
@@ -215,7 +219,7 @@ func UninstallYaml(clientSet *kubernetes.Clientset, dynamicClient dynamic.Interf
 
 	restMapperRes, err := restmapper.GetAPIGroupResources(dc)
 	if err != nil {
-		slog.Error(err.Error())
+		logger.Error("uninstall yaml", "file", filename, "error", err)
 		return err
 	}
 
@@ -228,30 +232,28 @@ func UninstallYaml(clientSet *kubernetes.Clientset, dynamicClient dynamic.Interf
 			if err == io.EOF {
 				break
 			}
-			slog.Error(err.Error())
+			logger.Error("uninstall yaml", "file", filename, "error", err)
 		}
 
 		// runtime.Object
 		obj, gvk, err := unstructured.UnstructuredJSONScheme.Decode(ext.Raw, nil, nil)
 		if err != nil {
-			slog.Error(err.Error())
+			logger.Error("uninstall yaml", "file", filename, "error", err)
 			return err
 		}
 
 		mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
-		// fmt.Printf("mapping:%+v\n", mapping)
 		if err != nil {
-			slog.Error(err.Error())
+			logger.Error("uninstall yaml", "file", filename, "error", err)
 			return err
 		}
 
 		// runtime.Object转换为unstructed
 		unstructuredObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
 		if err != nil {
-			slog.Error(err.Error())
+			logger.Error("uninstall yaml", "file", filename, "error", err)
 			return err
 		}
-		// fmt.Printf("unstructuredObj: %+v", unstructuredObj)
 
 		var unstruct unstructured.Unstructured
 
@@ -260,18 +262,18 @@ func UninstallYaml(clientSet *kubernetes.Clientset, dynamicClient dynamic.Interf
 		tmpMetadata := unstructuredObj["metadata"].(map[string]interface{})
 		tmpName := tmpMetadata["name"].(string)
 		tmpKind := unstructuredObj["kind"].(string)
-		slog.Info("deleting resource name: " + tmpName + ", kind: " + tmpKind + ", ns: " + ns)
+		logger.Info("deleting resource", "name", tmpName, "kind", tmpKind, "namespace", ns)
 
 		if ns == "" {
 			err := dynamicClient.Resource(mapping.Resource).Delete(context.TODO(), tmpName, metav1.DeleteOptions{})
 			if err != nil {
-				slog.Error(err.Error())
+				logger.Error("uninstall yaml", "file", filename, "error", err)
 				return err
 			}
 		} else {
 			err := dynamicClient.Resource(mapping.Resource).Namespace(ns).Delete(context.TODO(), tmpName, metav1.DeleteOptions{})
 			if err != nil {
-				slog.Error(err.Error())
+				logger.Error("uninstall yaml", "file", filename, "error", err)
 				return err
 			}
 		}
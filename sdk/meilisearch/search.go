@@ -1,18 +1,20 @@
 package meilisearch
 
 import (
-	"log/slog"
-
 	"github.com/meilisearch/meilisearch-go"
+
+	"github.com/lflxp/tools/logging"
 )
 
+var logger = logging.For("sdk/meilisearch")
+
 var (
 	SearchCli    *meilisearch.Client
 	Host, Apikey string
 )
 
 func InitMeili() {
-	slog.Debug("Meilisearch", "host", Host, "apikey", Apikey)
+	logger.Debug("Meilisearch", "host", Host, "apikey", Apikey)
 	SearchCli = meilisearch.NewClient(meilisearch.ClientConfig{
 		Host:   Host,
 		APIKey: Apikey,
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultOutputLimit 默认stdout、stderr和合并输出各自保留的字节数, 超出部分丢弃并设置Truncated, 回调不受影响
+const DefaultOutputLimit = 1 << 20
+
+// UnlimitedOutput 保留全部输出
+const UnlimitedOutput = math.MaxInt
+
+// MaxLineLength 按行回调时单行的最大长度, 超过时按该长度拆分回调, 避免没有换行的输出占满内存
+const MaxLineLength = 64 << 10
+
+// Command 命令参数, 默认不经过shell直接执行argv
+type Command struct {
+	Name string
+	Args []string
+	Dir  string
+	// Env 追加到当前进程的环境变量, ClearEnv为true时只使用Env
+	Env      []string
+	ClearEnv bool
+	Stdin    io.Reader
+	// Timeout 超时后杀死整个进程组, 0为不超时
+	Timeout time.Duration
+	// OnStdout/OnStderr 按行回调, 行不包含换行符
+	OnStdout func(line string)
+	OnStderr func(line string)
+	// OutputLimit stdout和stderr各自保留的字节数, 0使用DefaultOutputLimit, UnlimitedOutput不限制, 负数不保留
+	OutputLimit int
+	// CombinedLimit 按写入顺序交错的stdout+stderr合计保留的字节数, 取值含义同OutputLimit
+	CombinedLimit int
+}
+
+// CommandResult 执行结果, 进程已启动时总是返回
+type CommandResult struct {
+	ExitCode int    `json:"exitCode"`
+	Signal   string `json:"signal,omitempty"`
+	Stdout   []byte `json:"stdout"`
+	Stderr   []byte `json:"stderr"`
+	// Combined stdout和stderr按写入顺序交错的输出, 同exec.Cmd.CombinedOutput
+	Combined          []byte        `json:"combined"`
+	Truncated         bool          `json:"truncated"`
+	CombinedTruncated bool          `json:"combinedTruncated"`
+	TimedOut          bool          `json:"timedOut"`
+	Duration          time.Duration `json:"duration"`
+}
+
+var ErrCommandTimeout = errors.New("command timed out")
+
+// NewCommand 直接执行argv, 参数不会被shell解析
+func NewCommand(name string, args ...string) *Command {
+	return &Command{Name: name, Args: args}
+}
+
+// ShellCommand 使用 /bin/sh -c 执行, 只用于可信的命令
+func ShellCommand(cmd string) *Command {
+	return &Command{Name: "/bin/sh", Args: []string{"-c", cmd}}
+}
+
+// Run 执行命令, 退出码非0时返回*exec.ExitError, 超时返回ErrCommandTimeout
+// ctx取消或超时时杀死整个进程组, 避免子进程残留
+func (c *Command) Run(ctx context.Context) (*CommandResult, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Stdin = c.Stdin
+	if c.ClearEnv {
+		cmd.Env = c.Env
+	} else if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	// 孙进程持有管道时不无限等待
+	cmd.WaitDelay = 5 * time.Second
+
+	limit := c.OutputLimit
+	if limit == 0 {
+		limit = DefaultOutputLimit
+	}
+	combinedLimit := c.CombinedLimit
+	if combinedLimit == 0 {
+		combinedLimit = DefaultOutputLimit
+	}
+	combined := &combinedOutput{remaining: combinedLimit}
+	stdout := &outputWriter{remaining: limit, onLine: c.OnStdout, combined: combined}
+	stderr := &outputWriter{remaining: limit, onLine: c.OnStderr, combined: combined}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	result := &CommandResult{ExitCode: -1}
+	start := time.Now()
+	err := cmd.Run()
+	result.Duration = time.Since(start)
+	stdout.flush()
+	stderr.flush()
+	result.Stdout = stdout.buf.Bytes()
+	result.Stderr = stderr.buf.Bytes()
+	result.Truncated = stdout.truncated || stderr.truncated
+	result.Combined = combined.buf.Bytes()
+	result.CombinedTruncated = combined.truncated
+
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+		result.Signal = exitSignal(cmd.ProcessState)
+	} else if err != nil {
+		// 进程未启动
+		return nil, err
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		result.TimedOut = true
+		return result, fmt.Errorf("%w after %s: %s", ErrCommandTimeout, c.Timeout, c.Name)
+	}
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	return result, err
+}
+
+// outputWriter 每个流单独计算保留的字节数, exec对每个流只使用一个goroutine写入
+type outputWriter struct {
+	buf       bytes.Buffer
+	remaining int
+	truncated bool
+	onLine    func(line string)
+	pending   []byte
+	combined  *combinedOutput
+}
+
+// combinedOutput stdout和stderr共用, 两个流由不同goroutine写入需要加锁
+type combinedOutput struct {
+	lock      sync.Mutex
+	buf       bytes.Buffer
+	remaining int
+	truncated bool
+}
+
+func (c *combinedOutput) write(p []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.truncated = limitedWrite(&c.buf, &c.remaining, p) || c.truncated
+}
+
+// limitedWrite 最多写入remaining字节, 返回是否有数据被丢弃
+func limitedWrite(buf *bytes.Buffer, remaining *int, p []byte) bool {
+	n := len(p)
+	truncated := n > *remaining
+	if truncated {
+		n = max(*remaining, 0)
+	}
+	if n > 0 {
+		buf.Write(p[:n])
+		*remaining -= n
+	}
+	return truncated
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.truncated = limitedWrite(&w.buf, &w.remaining, p) || w.truncated
+	if w.combined != nil {
+		w.combined.write(p)
+	}
+	if w.onLine != nil {
+		w.pending = append(w.pending, p...)
+		for {
+			i := bytes.IndexByte(w.pending, '\n')
+			if i < 0 {
+				break
+			}
+			w.emit(bytes.TrimSuffix(w.pending[:i], []byte("\r")))
+			w.pending = w.pending[i+1:]
+		}
+		for len(w.pending) >= MaxLineLength {
+			w.onLine(string(w.pending[:MaxLineLength]))
+			w.pending = w.pending[MaxLineLength:]
+		}
+		// 释放已回调部分占用的底层数组
+		w.pending = append([]byte(nil), w.pending...)
+	}
+	return len(p), nil
+}
+
+// emit 超长的行按MaxLineLength拆分
+func (w *outputWriter) emit(line []byte) {
+	for len(line) > MaxLineLength {
+		w.onLine(string(line[:MaxLineLength]))
+		line = line[MaxLineLength:]
+	}
+	w.onLine(string(line))
+}
+
+func (w *outputWriter) flush() {
+	if w.onLine != nil && len(w.pending) > 0 {
+		w.onLine(string(w.pending))
+		w.pending = nil
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_CommandRun(t *testing.T) {
+	// 参数不经过shell
+	result, err := NewCommand("echo", "$HOME;", "a b").Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Stdout) != "$HOME; a b\n" || result.ExitCode != 0 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	var lines []string
+	cmd := ShellCommand(`echo one; echo two >&2; printf three; exit 3`)
+	cmd.Env = []string{"LFLXP=1"}
+	cmd.OnStdout = func(line string) { lines = append(lines, line) }
+	result, err = cmd.Run(context.Background())
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || result.ExitCode != 3 || string(result.Stderr) != "two\n" {
+		t.Fatalf("unexpected result %+v %v", result, err)
+	}
+	if strings.Join(lines, ",") != "one,three" {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+
+	// stdout和stderr各自计算限制
+	cmd = ShellCommand(`head -c 100 /dev/zero; echo err >&2`)
+	cmd.OutputLimit = 10
+	if result, _ = cmd.Run(context.Background()); len(result.Stdout) != 10 || string(result.Stderr) != "err\n" || !result.Truncated {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// 合并输出按写入顺序交错, 有单独的限制
+	cmd = ShellCommand(`echo out; sleep 0.05; echo err >&2; sleep 0.05; echo out2`)
+	if result, _ = cmd.Run(context.Background()); string(result.Combined) != "out\nerr\nout2\n" || result.CombinedTruncated {
+		t.Fatalf("unexpected combined %q", result.Combined)
+	}
+	cmd.CombinedLimit = 6
+	if result, _ = cmd.Run(context.Background()); string(result.Combined) != "out\ner" || !result.CombinedTruncated || result.Truncated || string(result.Stdout) != "out\nout2\n" {
+		t.Fatalf("unexpected combined %+v", result)
+	}
+
+	// 没有换行的输出按MaxLineLength拆分回调
+	lines = nil
+	cmd = ShellCommand(`head -c 150000 /dev/zero | tr '\0' a`)
+	cmd.OnStdout = func(line string) { lines = append(lines, line) }
+	if _, err = cmd.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 3 || len(lines[0]) != MaxLineLength || len(lines[2]) != 150000-2*MaxLineLength {
+		t.Fatalf("unexpected lines %d", len(lines))
+	}
+
+	if out, err := ExecCommandString("echo $((1+1))"); err != nil || out != "2\n" {
+		t.Fatalf("unexpected %q %v", out, err)
+	}
+	// 旧接口不限制输出大小
+	if out, err := ExecCommand("head -c 2000000 /dev/zero"); err != nil || len(out) != 2000000 {
+		t.Fatalf("unexpected %d %v", len(out), err)
+	}
+}
+
+func Test_CommandTimeout(t *testing.T) {
+	// 子进程也会被杀死, 不会等待sleep结束
+	cmd := ShellCommand(`sleep 10 & sleep 10`)
+	cmd.Timeout = 200 * time.Millisecond
+	start := time.Now()
+	result, err := cmd.Run(context.Background())
+	if !errors.Is(err, ErrCommandTimeout) || !result.TimedOut || result.Signal != "killed" {
+		t.Fatalf("unexpected result %+v %v", result, err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Fatalf("process group not killed, took %s", time.Since(start))
+	}
+}
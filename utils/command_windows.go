@@ -0,0 +1,21 @@
+//go:build windows
+
+package utils
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
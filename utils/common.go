@@ -1,50 +1,32 @@
 package utils
 
 import (
-	"bytes"
-	"compress/gzip"
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
-	"math/rand"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"sort"
 	"strings"
-	"text/template"
-	"time"
+
+	"github.com/lflxp/tools/credentials"
+	"github.com/lflxp/tools/logging"
 )
 
-// 写文件
-func WriteFile(path string, data []byte) (int, error) {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		return 0, err
-	}
+var logger = logging.For("utils")
 
-	return f.Write(data)
+// 追加写文件, 替换文件内容使用WriteFileAtomic, 长期写入的日志使用RotateWriter
+func WriteFile(path string, data []byte) (int, error) {
+	return AppendFile(path, data, 0666)
 }
 
-// 渲染模板
+// 渲染模板, 函数见TemplateFuncMap, 需要严格模式时使用ApplyTemplateWith
 func ApplyTemplate(temp string, data map[string]interface{}) (string, error) {
-	var out bytes.Buffer
-	// TODO: More FuncMap => https://blog.csdn.net/shida_csdn/article/details/113760434
-	// ctx := map[string]interface{}{}
-	// template.Must(template.New("demo").Funcs(funcMap).Parse(demoTmpl)).Execute(os.Stdout, ctx)
-	t := template.Must(template.New("now").Parse(temp))
-	err := t.Execute(&out, data)
-	if err != nil {
-		return "", err
-	}
-	return out.String(), nil
+	return ApplyTemplateWith(temp, data)
 }
 
 // 加密base64
@@ -59,6 +41,7 @@ func DecodeBase64(in string) (string, error) {
 }
 
 // 加密
+// Deprecated: MD5不能用于保存密码, 使用credentials.HashPassword, 旧hash可由credentials.VerifyAndUpgrade升级
 func Jiami(code string) string {
 	w := md5.New()
 	io.WriteString(w, code)
@@ -67,6 +50,7 @@ func Jiami(code string) string {
 }
 
 // 生成32位MD5
+// Deprecated: 不要用于保存密码, 使用credentials.HashPassword
 func MD5(text string) string {
 	ctx := md5.New()
 	ctx.Write([]byte(strings.TrimSpace(text)))
@@ -78,49 +62,44 @@ func GetRandomSalt() string {
 	return GetRandomString(32)
 }
 
-// 生成随机字符串
+// 生成随机字符串, 使用crypto/rand, len<=0或生成失败时返回空字符串
 func GetRandomString(len int) string {
-	str := "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	bytes := []byte(str)
-	result := []byte{}
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	for i := 0; i < len; i++ {
-		result = append(result, bytes[r.Intn(62)])
+	if len <= 0 {
+		return ""
+	}
+	s, err := credentials.RandomString(len, credentials.AlphabetAlphanumeric)
+	if err != nil {
+		logger.Error("generate random string", "error", err)
+		return ""
 	}
-	return string(result)
+	return s
 }
 
+// ExecCommand 使用/bin/sh -c执行, 失败时返回stderr
 func ExecCommand(cmd string) ([]byte, error) {
-	pipeline := exec.Command("/bin/sh", "-c", cmd)
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	pipeline.Stdout = &out
-	pipeline.Stderr = &stderr
-	err := pipeline.Run()
+	c := ShellCommand(cmd)
+	c.OutputLimit = UnlimitedOutput
+	c.CombinedLimit = -1
+	result, err := c.Run(context.Background())
 	if err != nil {
-		return stderr.Bytes(), err
+		if result == nil {
+			return nil, err
+		}
+		return result.Stderr, err
 	}
-	// fmt.Println(stderr.String())
-	return out.Bytes(), nil
+	return result.Stdout, nil
 }
 
 func ExecCommandString(cmd string) (string, error) {
-	pipeline := exec.Command("/bin/sh", "-c", cmd)
-	var out bytes.Buffer
-	var stderr bytes.Buffer
-	pipeline.Stdout = &out
-	pipeline.Stderr = &stderr
-	err := pipeline.Run()
-	if err != nil {
-		return stderr.String(), err
-	}
-	return out.String(), nil
+	out, err := ExecCommand(cmd)
+	return string(out), err
 }
 
 func GetCurrentDirectory() string {
 	dir, err := filepath.Abs(filepath.Dir(os.Args[0])) //返回绝对路径  filepath.Dir(os.Args[0])去除最后一个元素的路径
 	if err != nil {
-		log.Fatal(err)
+		logger.Error("get current directory", "error", err)
+		os.Exit(1)
 	}
 	return strings.Replace(dir, "\\", "/", -1) //将\替换成/
 }
@@ -129,7 +108,7 @@ func GetCurrentDirectory() string {
 func GetMacAddrs() (macAddrs []string) {
 	netInterfaces, err := net.Interfaces()
 	if err != nil {
-		fmt.Printf("fail to get net interfaces: %v", err)
+		logger.Error("get net interfaces", "error", err)
 		return macAddrs
 	}
 
@@ -148,14 +127,13 @@ func GetIPs() (ips []string) {
 
 	interfaceAddr, err := net.InterfaceAddrs()
 	if err != nil {
-		fmt.Printf("fail to get net interface addrs: %v", err)
+		logger.Error("get net interface addrs", "error", err)
 		return ips
 	}
 
 	for _, address := range interfaceAddr {
 		ipNet, isValidIpNet := address.(*net.IPNet)
 		if isValidIpNet && !ipNet.IP.IsLoopback() {
-			// fmt.Println(ipNet.IP.String(), ipNet.Mask.String())
 			if ipNet.IP.To4() != nil {
 				ips = append(ips, ipNet.IP.String())
 			}
@@ -181,46 +159,28 @@ func ContainsString(slice []string, s string) bool {
 	return false
 }
 
+// ParseJson 解析响应体为map, 编码和格式见DecodeBody; 解析到结构体等其他类型时使用DecodeBody
 func ParseJson(data string, header http.Header) (map[string]interface{}, error) {
 	if len(data) == 0 {
 		return nil, nil
 	}
 
 	var rs map[string]interface{}
-	// 判断数据是否是gzip压缩
-	if header != nil && strings.Contains(header.Get("Content-Encoding"), "gzip") {
-		var rs map[string]interface{}
-		reader, err := gzip.NewReader(strings.NewReader(data))
-		if err != nil {
-			return rs, err
-		}
-		decodeRs, err := ioutil.ReadAll(reader)
-		if err != nil {
-			return rs, err
-		}
-		data = string(decodeRs)
-	}
-
-	if err := json.Unmarshal([]byte(data), &rs); err != nil {
-		return rs, err
+	if err := DecodeBody(strings.NewReader(data), header, &rs); err != nil {
+		return nil, err
 	}
 	return rs, nil
 }
 
-// 快速判断字符是否在字符数组中
+// 判断字符是否在字符数组中, 不会修改source
 func In(target string, source []string) bool {
-	sort.Strings(source)
-	index := sort.SearchStrings(source, target)
-	if index < len(source) && source[index] == target {
-		return true
-	}
-	return false
+	return Contains(source, target)
 }
 
 func IsDir(path string) bool {
 	file, err := os.Stat(path)
 	if os.IsNotExist(err) {
-		fmt.Printf("%s is not exist", path)
+		logger.Debug("path not exist", "path", path)
 		return false
 	} else if err != nil {
 		return false
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultDecodeLimit 解压后的响应体大小上限, 防止解压炸弹
+const DefaultDecodeLimit = 32 << 20
+
+var (
+	ErrBodyTooLarge        = errors.New("body exceeds decode limit")
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+)
+
+// DecodeBody 按Content-Encoding解压、按Content-Type解析到v, v可以是任意类型或*json.RawMessage
+// 支持 gzip、deflate、br、zstd 以及 JSON、YAML、NDJSON(解析为数组), 未知类型按JSON处理
+func DecodeBody(r io.Reader, header http.Header, v interface{}) error {
+	return DecodeBodyLimit(r, header, v, DefaultDecodeLimit)
+}
+
+// DecodeBodyLimit limit为解压后的最大字节数
+func DecodeBodyLimit(r io.Reader, header http.Header, v interface{}, limit int64) error {
+	data, err := ReadBody(r, header, limit)
+	if err != nil {
+		return err
+	}
+	data, err = bodyToJSON(data, header)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ReadBody 读取并解压响应体, 超过limit时返回ErrBodyTooLarge
+func ReadBody(r io.Reader, header http.Header, limit int64) ([]byte, error) {
+	var encoding string
+	if header != nil {
+		encoding = header.Get("Content-Encoding")
+	}
+	rc, err := Decompress(r, encoding, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: %d bytes", ErrBodyTooLarge, limit)
+	}
+	return data, nil
+}
+
+// Decompress 按Content-Encoding解压, 多个编码按逆序解开, 如 "gzip, br"
+func Decompress(r io.Reader, encoding string, limit int64) (io.ReadCloser, error) {
+	encodings := strings.Split(encoding, ",")
+	rc := io.NopCloser(r)
+	closers := []io.Closer{}
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch strings.ToLower(strings.TrimSpace(encodings[i])) {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			rc, err = gzip.NewReader(rc)
+		case "deflate":
+			rc, err = newDeflateReader(rc)
+		case "br":
+			rc = io.NopCloser(brotli.NewReader(rc))
+		case "zstd":
+			var dec *zstd.Decoder
+			dec, err = zstd.NewReader(rc, zstd.WithDecoderMaxMemory(uint64(limit)), zstd.WithDecoderConcurrency(1))
+			if err == nil {
+				rc = dec.IOReadCloser()
+			}
+		default:
+			err = fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encodings[i])
+		}
+		if err != nil {
+			closeAll(closers)
+			return nil, err
+		}
+		closers = append(closers, rc)
+	}
+	return &multiCloser{Reader: rc, closers: closers}, nil
+}
+
+// newDeflateReader HTTP的deflate通常是zlib格式, 也有服务端直接返回raw deflate
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	closeAll(m.closers)
+	return nil
+}
+
+func closeAll(closers []io.Closer) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i].Close()
+	}
+}
+
+// bodyToJSON 将YAML、NDJSON转换为JSON
+func bodyToJSON(data []byte, header http.Header) ([]byte, error) {
+	var contentType string
+	if header != nil {
+		contentType, _, _ = mime.ParseMediaType(header.Get("Content-Type"))
+	}
+	switch {
+	case strings.HasSuffix(contentType, "yaml"):
+		return yaml.YAMLToJSON(data)
+	case contentType == "application/x-ndjson" || contentType == "application/jsonl" || contentType == "application/jsonlines":
+		return ndjsonToArray(data)
+	default:
+		return data, nil
+	}
+}
+
+func ndjsonToArray(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('[')
+	first := true
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, fmt.Errorf("ndjson line %d: invalid json", i+1)
+		}
+		if !first {
+			out.WriteByte(',')
+		}
+		out.Write(line)
+		first = false
+	}
+	out.WriteByte(']')
+	return out.Bytes(), nil
+}
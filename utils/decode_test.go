@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+	var w interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		enc, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = enc
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func Test_DecodeBody(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		header := http.Header{"Content-Encoding": {encoding}}
+		var items []map[string]interface{}
+		if err := DecodeBody(bytes.NewReader(compress(t, encoding, []byte(`[{"a":1},{"a":2}]`))), header, &items); err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if len(items) != 2 {
+			t.Fatalf("%s: unexpected %v", encoding, items)
+		}
+	}
+
+	var raw json.RawMessage
+	header := http.Header{"Content-Type": {"application/yaml"}}
+	if err := DecodeBody(strings.NewReader("kind: List\nitems:\n- a\n"), header, &raw); err != nil || string(raw) != `{"items":["a"],"kind":"List"}` {
+		t.Fatalf("yaml: unexpected %s %v", raw, err)
+	}
+
+	var rows []struct{ ID int }
+	header = http.Header{"Content-Type": {"application/x-ndjson"}}
+	if err := DecodeBody(strings.NewReader("{\"ID\":1}\n\n{\"ID\":2}\n"), header, &rows); err != nil || len(rows) != 2 || rows[1].ID != 2 {
+		t.Fatalf("ndjson: unexpected %v %v", rows, err)
+	}
+
+	// 解压后超过限制
+	bomb := compress(t, "gzip", bytes.Repeat([]byte(" "), 1<<20))
+	var v interface{}
+	if err := DecodeBodyLimit(bytes.NewReader(bomb), http.Header{"Content-Encoding": {"gzip"}}, &v, 1024); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("expected ErrBodyTooLarge, got %v", err)
+	}
+
+	if rs, err := ParseJson(string(compress(t, "gzip", []byte(`{"a":"b"}`))), http.Header{"Content-Encoding": {"gzip"}}); err != nil || rs["a"] != "b" {
+		t.Fatalf("ParseJson: unexpected %v %v", rs, err)
+	}
+}
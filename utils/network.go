@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProcNetRoute linux的路由表
+const ProcNetRoute = "/proc/net/route"
+
+// NetAddr 网卡地址
+type NetAddr struct {
+	IP      string `json:"ip"`
+	Prefix  int    `json:"prefix"`
+	CIDR    string `json:"cidr"`
+	Version int    `json:"version"`
+}
+
+// NetInterface 网卡信息
+type NetInterface struct {
+	Name     string    `json:"name"`
+	Index    int       `json:"index"`
+	MTU      int       `json:"mtu"`
+	MAC      string    `json:"mac,omitempty"`
+	Flags    []string  `json:"flags"`
+	Up       bool      `json:"up"`
+	Loopback bool      `json:"loopback"`
+	Addrs    []NetAddr `json:"addrs"`
+}
+
+// DefaultRoute 默认路由
+type DefaultRoute struct {
+	Interface string `json:"interface"`
+	Gateway   string `json:"gateway"`
+}
+
+// NetworkInventory 主机网络信息
+type NetworkInventory struct {
+	Hostname     string         `json:"hostname"`
+	Interfaces   []NetInterface `json:"interfaces"`
+	DefaultRoute *DefaultRoute  `json:"defaultRoute,omitempty"`
+}
+
+// NetworkFilter 过滤条件, 为空时返回全部
+type NetworkFilter struct {
+	// Names 网卡名通配符, 如 eth*、en*
+	Names []string
+	// CIDRs 只保留在这些网段内的地址, 没有匹配地址的网卡会被去掉
+	CIDRs []string
+	// UpOnly 只返回已启用的网卡
+	UpOnly bool
+	// SkipLoopback 跳过回环网卡
+	SkipLoopback bool
+}
+
+// virtualInterfacePrefixes 容器和虚拟网卡, 计算指纹时忽略, 避免容器启停导致指纹变化
+var virtualInterfacePrefixes = []string{"lo", "veth", "docker", "br-", "cni", "flannel", "cali", "virbr", "vxlan", "tun", "tap", "kube-ipvs", "tunl", "nodelocaldns"}
+
+// GetNetworkInventory 读取网卡、地址和默认路由, filter为nil时不过滤
+func GetNetworkInventory(filter *NetworkFilter) (*NetworkInventory, error) {
+	if filter == nil {
+		filter = &NetworkFilter{}
+	}
+	var nets []*net.IPNet
+	for _, cidr := range filter.CIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	inv := &NetworkInventory{Interfaces: []NetInterface{}}
+	inv.Hostname, _ = os.Hostname()
+
+	for _, iface := range ifaces {
+		item := NetInterface{
+			Name:     iface.Name,
+			Index:    iface.Index,
+			MTU:      iface.MTU,
+			MAC:      iface.HardwareAddr.String(),
+			Flags:    strings.Split(iface.Flags.String(), "|"),
+			Up:       iface.Flags&net.FlagUp != 0,
+			Loopback: iface.Flags&net.FlagLoopback != 0,
+			Addrs:    []NetAddr{},
+		}
+		if (filter.UpOnly && !item.Up) || (filter.SkipLoopback && item.Loopback) || !matchName(filter.Names, iface.Name) {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !containsIP(nets, ipNet.IP) {
+				continue
+			}
+			prefix, _ := ipNet.Mask.Size()
+			version := 6
+			if ipNet.IP.To4() != nil {
+				version = 4
+			}
+			item.Addrs = append(item.Addrs, NetAddr{IP: ipNet.IP.String(), Prefix: prefix, CIDR: ipNet.String(), Version: version})
+		}
+		if len(nets) > 0 && len(item.Addrs) == 0 {
+			continue
+		}
+		inv.Interfaces = append(inv.Interfaces, item)
+	}
+
+	// 非linux或没有权限时忽略默认路由
+	if f, err := os.Open(ProcNetRoute); err == nil {
+		inv.DefaultRoute, _ = ParseProcNetRoute(f)
+		f.Close()
+	}
+	return inv, nil
+}
+
+// Fingerprint 主机指纹: 主机名和物理网卡MAC排序后的sha256, 与网卡顺序和IP无关
+func (inv *NetworkInventory) Fingerprint() string {
+	macs := []string{}
+	for _, iface := range inv.Interfaces {
+		if iface.MAC == "" || iface.Loopback || isVirtualInterface(iface.Name) {
+			continue
+		}
+		macs = append(macs, strings.ToLower(iface.MAC))
+	}
+	sort.Strings(macs)
+	sum := sha256.Sum256([]byte(inv.Hostname + "\n" + strings.Join(Unique(macs), ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// HostFingerprint 当前主机的指纹
+func HostFingerprint() (string, error) {
+	inv, err := GetNetworkInventory(nil)
+	if err != nil {
+		return "", err
+	}
+	return inv.Fingerprint(), nil
+}
+
+// ParseProcNetRoute 解析/proc/net/route中的默认路由, 地址为小端序十六进制
+func ParseProcNetRoute(r io.Reader) (*DefaultRoute, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Iface Destination Gateway Flags ... Mask
+		if len(fields) < 8 || fields[0] == "Iface" || fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		gw, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parse gateway %q: %w", fields[2], err)
+		}
+		ip := make(net.IP, 4)
+		binary.LittleEndian.PutUint32(ip, uint32(gw))
+		return &DefaultRoute{Interface: fields[0], Gateway: ip.String()}, nil
+	}
+	return nil, scanner.Err()
+}
+
+func matchName(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if len(nets) == 0 {
+		return true
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isVirtualInterface(name string) bool {
+	for _, prefix := range virtualInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ParseProcNetRoute(t *testing.T) {
+	route := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	000200C0	00000000	0001	0	0	0	00FFFFFF	0	0	0
+eth0	00000000	010200C0	0003	0	0	0	00000000	0	0	0
+`
+	r, err := ParseProcNetRoute(strings.NewReader(route))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || r.Interface != "eth0" || r.Gateway != "192.0.2.1" {
+		t.Fatalf("unexpected route %+v", r)
+	}
+}
+
+func Test_NetworkInventoryFilter(t *testing.T) {
+	inv, err := GetNetworkInventory(&NetworkFilter{CIDRs: []string{"127.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iface := range inv.Interfaces {
+		for _, addr := range iface.Addrs {
+			if !strings.HasPrefix(addr.IP, "127.") || addr.Version != 4 {
+				t.Fatalf("unexpected addr %+v", addr)
+			}
+		}
+	}
+
+	inv, err = GetNetworkInventory(&NetworkFilter{Names: []string{"no-such-*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inv.Interfaces) != 0 {
+		t.Fatalf("expected no interfaces, got %d", len(inv.Interfaces))
+	}
+
+	if _, err := GetNetworkInventory(&NetworkFilter{CIDRs: []string{"bad"}}); err == nil {
+		t.Fatal("expected cidr error")
+	}
+}
+
+func Test_Fingerprint(t *testing.T) {
+	a := &NetworkInventory{Hostname: "node1", Interfaces: []NetInterface{
+		{Name: "eth0", MAC: "00:CF:E0:44:DD:BE"},
+		{Name: "eth1", MAC: "00:cf:e0:44:dd:bf"},
+		{Name: "veth123", MAC: "aa:aa:aa:aa:aa:aa"},
+	}}
+	// 网卡顺序、大小写和虚拟网卡不影响指纹
+	b := &NetworkInventory{Hostname: "node1", Interfaces: []NetInterface{
+		{Name: "eth1", MAC: "00:cf:e0:44:dd:bf"},
+		{Name: "eth0", MAC: "00:cf:e0:44:dd:be"},
+		{Name: "docker0", MAC: "bb:bb:bb:bb:bb:bb"},
+	}}
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Fatal("fingerprint should be stable")
+	}
+	b.Hostname = "node2"
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Fatal("fingerprint should depend on hostname")
+	}
+}
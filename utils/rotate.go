@@ -0,0 +1,320 @@
+package utils
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotateTimeFormat 备份文件名中的时间
+const rotateTimeFormat = "20060102T150405.000"
+
+// RotateWriter 按大小和时间滚动的日志文件, 可以作为slog handler的输出
+//
+//	w := utils.NewRotateWriter("logs/app.log")
+//	w.MaxSize = 100 << 20
+//	slog.SetDefault(slog.New(slog.NewJSONHandler(w, nil)))
+//
+// 滚动后的文件命名为 app-20060102T150405.000.log, Compress为true时在后台压缩为 .gz
+type RotateWriter struct {
+	Filename string
+	// MaxSize 单个文件的最大字节数, 0为不按大小滚动
+	MaxSize int64
+	// Interval 按时间滚动的周期, 如 24*time.Hour, 0为不按时间滚动
+	Interval time.Duration
+	// MaxBackups 保留的备份数量, 0为全部保留
+	MaxBackups int
+	// Compress 使用gzip压缩备份文件
+	Compress bool
+	// Perm 新建文件的权限, 默认0644
+	Perm os.FileMode
+
+	lock       sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	now        func() time.Time
+
+	millLock sync.Mutex
+	millWg   sync.WaitGroup
+}
+
+var _ io.WriteCloser = (*RotateWriter)(nil)
+
+// NewRotateWriter 默认100MiB滚动, 保留7个备份并压缩
+func NewRotateWriter(filename string) *RotateWriter {
+	return &RotateWriter{
+		Filename:   filename,
+		MaxSize:    100 << 20,
+		MaxBackups: 7,
+		Compress:   true,
+		Perm:       0644,
+	}
+}
+
+// Write 实现io.Writer, 写入前检查是否需要滚动, 可并发调用
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.shouldRotate(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate 立即滚动当前文件
+func (w *RotateWriter) Rotate() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	return w.rotate()
+}
+
+// Sync 刷新到磁盘
+func (w *RotateWriter) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close 关闭文件并等待后台压缩和清理完成
+func (w *RotateWriter) Close() error {
+	w.lock.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.lock.Unlock()
+	w.millWg.Wait()
+	return err
+}
+
+func (w *RotateWriter) timeNow() time.Time {
+	if w.now != nil {
+		return w.now()
+	}
+	return time.Now()
+}
+
+func (w *RotateWriter) perm() os.FileMode {
+	if w.Perm == 0 {
+		return 0644
+	}
+	return w.Perm
+}
+
+func (w *RotateWriter) shouldRotate(n int64) bool {
+	if w.MaxSize > 0 && w.size > 0 && w.size+n > w.MaxSize {
+		return true
+	}
+	return w.Interval > 0 && !w.timeNow().Before(w.nextRotate)
+}
+
+// open 打开已有文件继续追加, 文件已超过MaxSize时下次写入会滚动
+func (w *RotateWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.Filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.Filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, w.perm())
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.file, w.size = f, info.Size()
+	w.resetNextRotate()
+	return nil
+}
+
+func (w *RotateWriter) resetNextRotate() {
+	if w.Interval > 0 {
+		w.nextRotate = w.timeNow().Truncate(w.Interval).Add(w.Interval)
+	}
+}
+
+// rotate 关闭失败时只记录日志, 继续滚动并打开新文件, 避免后续写入一直使用已失效的文件
+func (w *RotateWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		logger.Error("close log file before rotate", "file", w.Filename, "error", err)
+	}
+	w.file = nil
+	if err := os.Rename(w.Filename, w.backupName(w.timeNow())); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	f, err := os.OpenFile(w.Filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, w.perm())
+	if err != nil {
+		return err
+	}
+	w.file, w.size = f, 0
+	w.resetNextRotate()
+
+	w.millWg.Add(1)
+	go func() {
+		defer w.millWg.Done()
+		if err := w.mill(); err != nil {
+			logger.Error("rotate log file", "file", w.Filename, "error", err)
+		}
+	}()
+	return nil
+}
+
+func (w *RotateWriter) nameParts() (dir, prefix, ext string) {
+	dir = filepath.Dir(w.Filename)
+	base := filepath.Base(w.Filename)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// backupName 同一毫秒内多次滚动时追加序号, 如 app-20060102T150405.000-1.log, 避免覆盖已有备份
+func (w *RotateWriter) backupName(t time.Time) string {
+	dir, prefix, ext := w.nameParts()
+	base := filepath.Join(dir, prefix+t.Format(rotateTimeFormat))
+	name := base + ext
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = base + "-" + strconv.Itoa(i) + ext
+	}
+	return name
+}
+
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// parseBackup 解析备份文件名中的时间和序号
+func parseBackup(stamp string) (time.Time, int, bool) {
+	seq := 0
+	if i := strings.LastIndexByte(stamp, '-'); i > 0 {
+		n, err := strconv.Atoi(stamp[i+1:])
+		if err != nil || n <= 0 {
+			return time.Time{}, 0, false
+		}
+		stamp, seq = stamp[:i], n
+	}
+	t, err := time.Parse(rotateTimeFormat, stamp)
+	return t, seq, err == nil
+}
+
+// Backups 返回备份文件, 从新到旧排序
+func (w *RotateWriter) Backups() ([]string, error) {
+	dir, prefix, ext := w.nameParts()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type backup struct {
+		name string
+		t    time.Time
+		seq  int
+	}
+	var found []backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		ts := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz"), ext)
+		t, seq, ok := parseBackup(ts)
+		if !ok {
+			continue
+		}
+		found = append(found, backup{filepath.Join(dir, name), t, seq})
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if !found[i].t.Equal(found[j].t) {
+			return found[i].t.After(found[j].t)
+		}
+		return found[i].seq > found[j].seq
+	})
+	backups := make([]string, len(found))
+	for i, b := range found {
+		backups[i] = b.name
+	}
+	return backups, nil
+}
+
+// mill 压缩备份并删除超出MaxBackups的旧文件
+func (w *RotateWriter) mill() error {
+	w.millLock.Lock()
+	defer w.millLock.Unlock()
+
+	backups, err := w.Backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, name := range backups {
+		if w.MaxBackups > 0 && i >= w.MaxBackups {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if w.Compress && !strings.HasSuffix(name, ".gz") {
+			if err := gzipFile(name, w.perm()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// gzipFile 压缩为 name.gz 后删除原文件
+func gzipFile(name string, perm os.FileMode) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_WriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := WriteFileAtomic(path, []byte("a: 1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(path, []byte("a: 2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	info, _ := os.Stat(path)
+	if string(data) != "a: 2" || info.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected file %q %v", data, info.Mode())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Fatalf("temp file left behind: %d entries", len(entries))
+	}
+}
+
+func Test_RotateWriterSize(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := &RotateWriter{Filename: filepath.Join(dir, "app.log"), MaxSize: 10, MaxBackups: 2, Compress: true}
+	w.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, _ := os.ReadFile(w.Filename)
+	if string(current) != "fourth\n" {
+		t.Fatalf("unexpected current file %q", current)
+	}
+	backups, err := w.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v", backups)
+	}
+	// 最新的备份是third
+	if !strings.HasSuffix(backups[0], ".log.gz") {
+		t.Fatalf("backup not compressed: %s", backups[0])
+	}
+	f, _ := os.Open(backups[0])
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(gz)
+	if string(data) != "third\n" {
+		t.Fatalf("unexpected backup %q", data)
+	}
+}
+
+func Test_RotateWriterInterval(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	w := &RotateWriter{Filename: filepath.Join(t.TempDir(), "app.log"), Interval: 24 * time.Hour}
+	w.now = func() time.Time { return now }
+	defer w.Close()
+
+	// 作为slog的输出
+	logger := slog.New(slog.NewTextHandler(w, nil))
+	logger.Info("before midnight")
+	now = now.Add(2 * time.Hour)
+	logger.Info("after midnight")
+
+	backups, _ := w.Backups()
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 backup, got %v", backups)
+	}
+	current, _ := os.ReadFile(w.Filename)
+	if !strings.Contains(string(current), "after midnight") || strings.Contains(string(current), "before midnight") {
+		t.Fatalf("unexpected current file %q", current)
+	}
+}
+
+func Test_RotateWriterSameMillisecond(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	w := &RotateWriter{Filename: filepath.Join(t.TempDir(), "app.log")}
+	w.now = func() time.Time { return now }
+	defer w.Close()
+
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, _ := w.Backups()
+	if len(backups) != 3 || !strings.HasSuffix(backups[0], "-2.log") {
+		t.Fatalf("unexpected backups %v", backups)
+	}
+	// 最新的备份排在最前
+	if data, _ := os.ReadFile(backups[0]); string(data) != "c\n" {
+		t.Fatalf("unexpected newest backup %q", data)
+	}
+
+	// 关闭失败时仍然打开新文件
+	w.file.Close()
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("d\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(w.Filename); string(data) != "d\n" {
+		t.Fatalf("unexpected current file %q", data)
+	}
+}
@@ -0,0 +1,140 @@
+package utils
+
+// Set 基于map的集合, 只用于成员判断, 需要稳定顺序时使用下面的切片函数
+type Set[T comparable] map[T]struct{}
+
+// NewSet 创建集合
+func NewSet[T comparable](items ...T) Set[T] {
+	s := make(Set[T], len(items))
+	s.Add(items...)
+	return s
+}
+
+func (s Set[T]) Add(items ...T) {
+	for _, item := range items {
+		s[item] = struct{}{}
+	}
+}
+
+func (s Set[T]) Remove(items ...T) {
+	for _, item := range items {
+		delete(s, item)
+	}
+}
+
+func (s Set[T]) Has(item T) bool {
+	_, ok := s[item]
+	return ok
+}
+
+func (s Set[T]) Len() int {
+	return len(s)
+}
+
+// Contains 切片是否包含v
+func Contains[T comparable](s []T, v T) bool {
+	for _, item := range s {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Unique 去重, 保留第一次出现的顺序
+func Unique[T comparable](s []T) []T {
+	seen := make(Set[T], len(s))
+	result := make([]T, 0, len(s))
+	for _, item := range s {
+		if !seen.Has(item) {
+			seen.Add(item)
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Union a+b去重, 按a、b的顺序
+func Union[T comparable](a, b []T) []T {
+	result := make([]T, 0, len(a)+len(b))
+	result = append(result, a...)
+	return Unique(append(result, b...))
+}
+
+// Intersect 同时存在于a和b的元素, 按a的顺序, 保留a中的重复项
+func Intersect[T comparable](a, b []T) []T {
+	set := NewSet(b...)
+	result := make([]T, 0)
+	for _, item := range a {
+		if set.Has(item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Difference a中存在b中不存在的元素, 按a的顺序, 保留a中的重复项
+func Difference[T comparable](a, b []T) []T {
+	set := NewSet(b...)
+	result := make([]T, 0)
+	for _, item := range a {
+		if !set.Has(item) {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// SymmetricDifference 只存在于a或b其中之一的元素, 先a后b
+func SymmetricDifference[T comparable](a, b []T) []T {
+	return append(Difference(a, b), Difference(b, a)...)
+}
+
+// Remove 删除所有等于v的元素, 不修改原切片
+func Remove[T comparable](s []T, v T) []T {
+	result := make([]T, 0, len(s))
+	for _, item := range s {
+		if item != v {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Partition 按条件拆分为满足和不满足的两部分, 各自保持原顺序
+func Partition[T any](s []T, pred func(T) bool) (matched, rest []T) {
+	matched, rest = make([]T, 0), make([]T, 0)
+	for _, item := range s {
+		if pred(item) {
+			matched = append(matched, item)
+		} else {
+			rest = append(rest, item)
+		}
+	}
+	return matched, rest
+}
+
+// Chunk 按size分块, 最后一块可能不足size, size<=0时返回nil
+func Chunk[T any](s []T, size int) [][]T {
+	if size <= 0 {
+		return nil
+	}
+	chunks := make([][]T, 0, (len(s)+size-1)/size)
+	for size < len(s) {
+		s, chunks = s[size:], append(chunks, s[:size:size])
+	}
+	if len(s) > 0 {
+		chunks = append(chunks, s)
+	}
+	return chunks
+}
+
+// GroupBy 按key分组, 组内保持原顺序
+func GroupBy[T any, K comparable](s []T, key func(T) K) map[K][]T {
+	groups := map[K][]T{}
+	for _, item := range s {
+		k := key(item)
+		groups[k] = append(groups[k], item)
+	}
+	return groups
+}
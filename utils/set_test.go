@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func Test_SetOperations(t *testing.T) {
+	a := []string{"c", "a", "b", "a"}
+	b := []string{"b", "d", "c"}
+
+	cases := []struct {
+		name      string
+		got, want []string
+	}{
+		{"Unique", Unique(a), []string{"c", "a", "b"}},
+		{"Union", Union(a, b), []string{"c", "a", "b", "d"}},
+		{"Intersect", Intersect(a, b), []string{"c", "b"}},
+		{"Difference", Difference(a, b), []string{"a", "a"}},
+		{"SymmetricDifference", SymmetricDifference(a, b), []string{"a", "a", "d"}},
+		{"Remove", Remove(a, "a"), []string{"c", "b"}},
+		{"SliceJoinBySlice", SliceJoinBySlice(nil, nil), []string{}},
+	}
+	for _, c := range cases {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Fatalf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+
+	source := []string{"b", "a"}
+	if !In("a", source) || source[0] != "b" {
+		t.Fatalf("In modified source: %v", source)
+	}
+
+	even, odd := Partition([]int{1, 2, 3, 4, 5}, func(i int) bool { return i%2 == 0 })
+	if !reflect.DeepEqual(even, []int{2, 4}) || !reflect.DeepEqual(odd, []int{1, 3, 5}) {
+		t.Fatalf("unexpected partition %v %v", even, odd)
+	}
+	if chunks := Chunk([]int{1, 2, 3, 4, 5}, 2); !reflect.DeepEqual(chunks, [][]int{{1, 2}, {3, 4}, {5}}) {
+		t.Fatalf("unexpected chunks %v", chunks)
+	}
+	groups := GroupBy([]string{"apple", "avocado", "banana"}, func(s string) byte { return s[0] })
+	if !reflect.DeepEqual(groups['a'], []string{"apple", "avocado"}) || len(groups['b']) != 1 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+}
+
+func benchSlices(n int) (a, b []string) {
+	for i := 0; i < n; i++ {
+		a = append(a, strconv.Itoa(i))
+		b = append(b, strconv.Itoa(i+n/2))
+	}
+	return a, b
+}
+
+func BenchmarkDifference(b *testing.B) {
+	s1, s2 := benchSlices(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Difference(s1, s2)
+	}
+}
+
+func BenchmarkUnion(b *testing.B) {
+	s1, s2 := benchSlices(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Union(s1, s2)
+	}
+}
+
+func BenchmarkDiffSlice(b *testing.B) {
+	s1, s2 := benchSlices(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DiffSlice(s1, s2)
+	}
+}
@@ -1,7 +1,6 @@
 package utils
 
 import (
-	mapset "github.com/deckarep/golang-set"
 	"strings"
 )
 
@@ -26,97 +25,37 @@ func DiffSlice(oldSlice, newSlice []string) (deletes, adds []string) {
 
 // SliceEqual s1和s2数组是否数据一致 - 注意这里不判断顺序一致 只判断字符是否存在
 func SliceEqual(s1, s2 []string) bool {
-	if len(SliceOnlyBySlice(s1, s2)) == 0 && len(SliceOnlyBySlice(s2, s1)) == 0 {
-		return true
-	}
-	return false
+	return len(SymmetricDifference(s1, s2)) == 0
 }
 
 // SliceBothBySlice 返回同时存在s1和s2数组
 func SliceBothBySlice(s1, s2 []string) []string {
-	both := []string{}
-	for _, s1One := range s1 {
-		if SliceContainer(s2, s1One) {
-			both = append(both, s1One)
-		}
-	}
-	return both
+	return Intersect(s1, s2)
 }
 
-// SliceJoinBySlice 返回s1+s2数组 - 去重
+// SliceJoinBySlice 返回s1+s2数组 - 去重, 保持原顺序
 func SliceJoinBySlice(s1, s2 []string) []string {
-	result := []string{}
-	set := mapset.NewSet()
-	if s1 != nil {
-		for _, v := range s1 {
-			set.Add(v)
-		}
-	}
-	if s2 != nil {
-		for _, v := range s2 {
-			set.Add(v)
-		}
-	}
-
-	for _, v := range set.ToSlice() {
-		result = append(result, v.(string))
-	}
-	return result
+	return Union(s1, s2)
 }
 
 // SliceOnlyBySlice 返回s1存在但是s2不存在的数组
 func SliceOnlyBySlice(s1, s2 []string) []string {
-	s1Only := []string{}
-	for _, s1One := range s1 {
-		if !SliceContainer(s2, s1One) {
-			// s2 不存在 s1One - 添加到 s1Only
-			s1Only = append(s1Only, s1One)
-		}
-	}
-	return s1Only
+	return Difference(s1, s2)
 }
 
 // SliceContainer 数组是否存在sub字符串
 func SliceContainer(s []string, sub string) bool {
-	if s == nil || len(s) == 0 {
-		return false
-	}
-	for _, subS := range s {
-		if subS == sub {
-			return true
-		}
-	}
-	return false
+	return Contains(s, sub)
 }
 
 // SliceRemoveStr 删除数组中存在的str 返回返回剩余项
 func SliceRemoveStr(s []string, sub string) []string {
-	result := []string{}
-	if s == nil || len(s) == 0 {
-		return result
-	}
-	for _, subS := range s {
-		if subS != sub {
-			result = append(result, subS)
-		}
-	}
-	return result
+	return Remove(s, sub)
 }
 
-// SliceRemoveDuplication 数组去重: 顺序会乱
+// SliceRemoveDuplication 数组去重, 保留第一次出现的顺序
 func SliceRemoveDuplication(list []string) []string {
-	result := []string{}
-	if list == nil || len(list) == 0 {
-		return result
-	}
-	set := mapset.NewSet()
-	for _, v := range list {
-		set.Add(v)
-	}
-	for _, v := range set.ToSlice() {
-		result = append(result, v.(string))
-	}
-	return result
+	return Unique(list)
 }
 
 func DelMapElement(m map[string]string, key string) {
@@ -0,0 +1,338 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// maxTemplateCache 缓存的模板数量上限, 超过后清空重新缓存
+const maxTemplateCache = 256
+
+var (
+	templateCacheLock sync.RWMutex
+	templateCache     = map[string]*template.Template{}
+)
+
+// TemplateOption ApplyTemplateWith的选项
+type TemplateOption func(*templateOptions)
+
+type templateOptions struct {
+	strict bool
+}
+
+// WithStrict 缺少key时返回错误, 默认输出<no value>
+func WithStrict() TemplateOption {
+	return func(o *templateOptions) {
+		o.strict = true
+	}
+}
+
+// ApplyTemplateWith 渲染模板, 编译结果按内容hash缓存
+// 解析和执行错误包含行号, 如 template: tpl:3: function "foo" not defined
+func ApplyTemplateWith(temp string, data interface{}, opts ...TemplateOption) (string, error) {
+	o := &templateOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	t, err := ParseTemplate(temp, o.strict)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// ParseTemplate 编译模板并加入缓存, 函数见TemplateFuncMap
+func ParseTemplate(temp string, strict bool) (*template.Template, error) {
+	sum := sha256.Sum256([]byte(temp))
+	key := hex.EncodeToString(sum[:]) + strconv.FormatBool(strict)
+
+	templateCacheLock.RLock()
+	t, ok := templateCache[key]
+	templateCacheLock.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	t = template.New("tpl").Funcs(TemplateFuncMap())
+	if strict {
+		t = t.Option("missingkey=error")
+	}
+	t, err := t.Parse(temp)
+	if err != nil {
+		return nil, err
+	}
+
+	templateCacheLock.Lock()
+	if len(templateCache) >= maxTemplateCache {
+		templateCache = map[string]*template.Template{}
+	}
+	templateCache[key] = t
+	templateCacheLock.Unlock()
+	return t, nil
+}
+
+// TemplateFuncMap 模板函数, 命名与sprig一致
+func TemplateFuncMap() template.FuncMap {
+	return template.FuncMap{
+		// string
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      strings.Title,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+		"quote":      func(v interface{}) string { return strconv.Quote(toString(v)) },
+		"squote":     func(v interface{}) string { return "'" + toString(v) + "'" },
+		"trunc":      trunc,
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"toString":   toString,
+
+		// math
+		"add": func(a, b interface{}) int64 { return toInt64(a) + toInt64(b) },
+		"sub": func(a, b interface{}) int64 { return toInt64(a) - toInt64(b) },
+		"mul": func(a, b interface{}) int64 { return toInt64(a) * toInt64(b) },
+		"div": func(a, b interface{}) (int64, error) {
+			if toInt64(b) == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return toInt64(a) / toInt64(b), nil
+		},
+		"mod": func(a, b interface{}) (int64, error) {
+			if toInt64(b) == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return toInt64(a) % toInt64(b), nil
+		},
+		"max": func(a, b interface{}) int64 {
+			if toInt64(a) > toInt64(b) {
+				return toInt64(a)
+			}
+			return toInt64(b)
+		},
+		"min": func(a, b interface{}) int64 {
+			if toInt64(a) < toInt64(b) {
+				return toInt64(a)
+			}
+			return toInt64(b)
+		},
+		"atoi": func(s string) (int, error) { return strconv.Atoi(s) },
+
+		// date
+		"now":       time.Now,
+		"date":      date,
+		"unixEpoch": func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) },
+		"toDate":    time.Parse,
+
+		// encoding
+		"b64enc":    func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":    func(s string) (string, error) { b, err := base64.StdEncoding.DecodeString(s); return string(b), err },
+		"md5sum":    func(s string) string { sum := md5.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"sha1sum":   func(s string) string { sum := sha1.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"sha256sum": func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"toJson":    toJSON,
+		"toPrettyJson": func(v interface{}) (string, error) {
+			b, err := json.MarshalIndent(v, "", "  ")
+			return string(b), err
+		},
+		"fromJson": func(s string) (interface{}, error) {
+			var v interface{}
+			err := json.Unmarshal([]byte(s), &v)
+			return v, err
+		},
+		"toYaml": func(v interface{}) (string, error) {
+			b, err := yaml.Marshal(v)
+			return strings.TrimSuffix(string(b), "\n"), err
+		},
+
+		// flow
+		"default":  func(def, v interface{}) interface{} { return ternary(empty(v), def, v) },
+		"empty":    empty,
+		"coalesce": coalesce,
+		"ternary":  func(a, b interface{}, cond bool) interface{} { return ternary(cond, a, b) },
+		"required": func(msg string, v interface{}) (interface{}, error) {
+			if empty(v) {
+				return nil, errors.New(msg)
+			}
+			return v, nil
+		},
+		"fail": func(msg string) (string, error) { return "", errors.New(msg) },
+
+		// map/list
+		"dict":   dict,
+		"list":   func(v ...interface{}) []interface{} { return v },
+		"hasKey": func(m map[string]interface{}, key string) bool { _, ok := m[key]; return ok },
+		"lookup": lookup,
+		"keys": func(m map[string]interface{}) []string {
+			keys := make([]string, 0, len(m))
+			for k := range m {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return keys
+		},
+	}
+}
+
+func toString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case nil:
+		return ""
+	case fmt.Stringer:
+		return s.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toInt64(v interface{}) int64 {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float())
+	case reflect.String:
+		i, _ := strconv.ParseInt(rv.String(), 10, 64)
+		return i
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1
+		}
+	}
+	return 0
+}
+
+func join(sep string, v interface{}) string {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return toString(v)
+	}
+	items := make([]string, rv.Len())
+	for i := range items {
+		items[i] = toString(rv.Index(i).Interface())
+	}
+	return strings.Join(items, sep)
+}
+
+func trunc(n int, s string) string {
+	r := []rune(s)
+	if n < 0 || n >= len(r) {
+		return s
+	}
+	return string(r[:n])
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+// date 使用Go的时间格式, 如 2006-01-02, 支持time.Time、unix时间戳
+func date(layout string, v interface{}) string {
+	switch t := v.(type) {
+	case time.Time:
+		return t.Format(layout)
+	case *time.Time:
+		return t.Format(layout)
+	default:
+		return time.Unix(toInt64(v), 0).Format(layout)
+	}
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// empty 零值、空字符串、空集合为空
+func empty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
+
+func coalesce(v ...interface{}) interface{} {
+	for _, item := range v {
+		if !empty(item) {
+			return item
+		}
+	}
+	return nil
+}
+
+func ternary(cond bool, a, b interface{}) interface{} {
+	if cond {
+		return a
+	}
+	return b
+}
+
+func dict(v ...interface{}) (map[string]interface{}, error) {
+	if len(v)%2 != 0 {
+		return nil, errors.New("dict requires key value pairs")
+	}
+	m := make(map[string]interface{}, len(v)/2)
+	for i := 0; i < len(v); i += 2 {
+		m[toString(v[i])] = v[i+1]
+	}
+	return m, nil
+}
+
+// lookup 按 a.b.c 路径查找嵌套map中的值, 不存在时返回nil
+func lookup(path string, v interface{}) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		item := rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key()))
+		if !item.IsValid() {
+			return nil
+		}
+		v = item.Interface()
+	}
+	return v
+}
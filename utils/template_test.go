@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_ApplyTemplateFuncs(t *testing.T) {
+	data := map[string]interface{}{
+		"name":   "lflxp",
+		"port":   8080,
+		"labels": map[string]interface{}{"app": map[string]interface{}{"tier": "web"}},
+	}
+	cases := map[string]string{
+		`{{ .name | upper | quote }}`:                       `"LFLXP"`,
+		`{{ add .port 1 }}`:                                 `8081`,
+		`{{ .missing | default "none" }}`:                   `none`,
+		`{{ .name | b64enc | b64dec }}`:                     `lflxp`,
+		`{{ lookup "app.tier" .labels }}`:                   `web`,
+		`{{ .labels | toJson }}`:                            `{"app":{"tier":"web"}}`,
+		`{{ dict "a" 1 | toYaml }}`:                         `a: 1`,
+		`{{ list "a" "b" | join "," }}`:                     `a,b`,
+		`spec:{{ dict "port" .port | toYaml | nindent 2 }}`: "spec:\n  port: 8080",
+		`{{ "abc" | sha256sum | trunc 8 }}`:                 `ba7816bf`,
+	}
+	for tpl, want := range cases {
+		got, err := ApplyTemplate(tpl, data)
+		if err != nil {
+			t.Fatalf("%s: %v", tpl, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %q, want %q", tpl, got, want)
+		}
+	}
+}
+
+func Test_ApplyTemplateErrors(t *testing.T) {
+	if _, err := ApplyTemplate("line1\n{{ .name | nosuchfunc }}", nil); err == nil || !strings.Contains(err.Error(), "tpl:2") {
+		t.Fatalf("expected parse error with line number, got %v", err)
+	}
+	if _, err := ApplyTemplate(`{{ required "name is required" .name }}`, map[string]interface{}{}); err == nil || !strings.Contains(err.Error(), "name is required") {
+		t.Fatalf("expected required error, got %v", err)
+	}
+	if _, err := ApplyTemplateWith(`{{ .name }}`, map[string]interface{}{}, WithStrict()); err == nil {
+		t.Fatal("expected missing key error")
+	}
+	if got, err := ApplyTemplateWith(`{{ div 1 0 }}`, nil); err == nil {
+		t.Fatalf("expected division error, got %q", got)
+	}
+}